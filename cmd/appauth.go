@@ -24,6 +24,7 @@ func addAppAuth(cmd *cobra.Command) {
 	var bindAddress string
 	var noBrowser bool
 	var usePKCE bool
+	var display string
 
 	scopes := []string{}
 
@@ -40,13 +41,20 @@ func addAppAuth(cmd *cobra.Command) {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			endpoint, err := openid.Discover(cmd.Context(), issuerURI)
+			endpoint, err := openid.Discover(discoverContext(cmd), issuerURI)
 			if err != nil {
 				return err
 			}
 			var src oauth2.TokenSource
 
-			var opts []appauth.Option
+			opts := []appauth.Option{
+				appauth.UseEndpoint(endpoint),
+				appauth.UseUserAgent(userAgent()),
+			}
+
+			if w := debugWriter(cmd); w != nil {
+				opts = append(opts, appauth.UseHTTPDebugLogger(w))
+			}
 
 			if bindAddress != "" {
 				opts = append(opts, appauth.UseBindAddress([]string{bindAddress}))
@@ -56,6 +64,10 @@ func addAppAuth(cmd *cobra.Command) {
 				opts = append(opts, appauth.UseRedirectHostname(redirectHostname))
 			}
 
+			if display != "" {
+				opts = append(opts, appauth.UseDisplay(display))
+			}
+
 			if noBrowser {
 				opts = append(opts, appauth.UseURLOpener(types.PromptOpener(types.StdoutPrompter)))
 			}
@@ -67,7 +79,7 @@ func addAppAuth(cmd *cobra.Command) {
 			}
 
 			if !noCache {
-				src = cachedSource(src, endpoint.TokenURL, clientID, cachePath)
+				src = cachedSource(src, endpoint.TokenURL, clientID, cachePath, debugWriter(cmd))
 			}
 
 			token, err := src.Token()
@@ -97,6 +109,8 @@ func addAppAuth(cmd *cobra.Command) {
 	appAuth.Flags().BoolVar(&usePKCE, "pkce", false, "use native app PKCE grant flow")
 	appAuth.Flags().StringArrayVar(&scopes, "scopes", []string{gooidc.ScopeOpenID, gooidc.ScopeOfflineAccess}, "scope used to request new token")
 
+	appAuth.Flags().StringVar(&display, "display", "", "how the provider renders the login page, one of page, popup, touch and wap")
+
 	appAuth.Flags().StringVarP(&redirectHostname, "redirect-hostname", "r", "127.0.0.1", "The RFC8252 requires 127.0.0.1 address to for safety reason, user can set this if the provider does not accept 127.0.0.1 as redirect url")
 	appAuth.Flags().StringVarP(&bindAddress, "bind", "b", "", "Provides a way to bind local server on pre-configured addresses. The RFC8252 requires port to be any port when using loopback interface redirection, hence the default behavior is using first free port and 127.0.0.1 address")
 
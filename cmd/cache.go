@@ -1,16 +1,23 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/tiewei/otoken/pkg/refresher"
 	"github.com/tiewei/otoken/pkg/tokenstore"
+	"github.com/tiewei/otoken/pkg/types"
 	"golang.org/x/oauth2"
 )
 
-func initCache(cacheBase string) error {
+// expandCachePath expands the leading `~/` and `./` of cache path.
+func expandCachePath(cacheBase string) string {
 	if strings.HasPrefix(cacheBase, "~/") {
 		home, _ := os.UserHomeDir()
 		cacheBase = strings.Replace(cacheBase, "~", home, 1)
@@ -18,16 +25,53 @@ func initCache(cacheBase string) error {
 	if strings.HasPrefix(cacheBase, "./") {
 		cacheBase = strings.TrimLeft(cacheBase, "./")
 	}
-	return os.MkdirAll(cacheBase, 0700)
+	return cacheBase
+}
+
+func initCache(cacheBase string) error {
+	return os.MkdirAll(expandCachePath(cacheBase), 0700)
+}
+
+func cachedSource(src oauth2.TokenSource, tokenURL string, clientID string, cacheBase string, debug io.Writer) oauth2.TokenSource {
+	return cachedSourceWithKey(src, tokenURL, clientID, clientID, cacheBase, debug)
+}
+
+// scopedCacheKey returns the cache key of clientID and scopes, hence
+// tokens of different scopes don't collide. The order of scopes is ignored.
+func scopedCacheKey(clientID string, scopes []string) string {
+	sorted := append([]string{}, scopes...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, " ")))
+	return clientID + "-" + hex.EncodeToString(sum[:8])
+}
+
+// jwtBearerCacheKey returns the cache key of the jwt-bearer token of subject.
+func jwtBearerCacheKey(clientID string, subject string, scopes []string) string {
+	return scopedCacheKey(clientID+"-"+subject, scopes)
+}
+
+// passwordCacheKey returns the cache key of the password grant token of
+// username, it's clientID if the username is unknown until prompted.
+func passwordCacheKey(clientID string, username string) string {
+	if username == "" {
+		return clientID
+	}
+	return clientID + "-" + url.PathEscape(username)
 }
 
-func cachedSource(src oauth2.TokenSource, tokenURL string, clientID string, cacheBase string) oauth2.TokenSource {
+// cachedSourceWithKey is the same as cachedSource, but saves the token in
+// the file named key.
+func cachedSourceWithKey(src oauth2.TokenSource, tokenURL string, clientID string, key string, cacheBase string, debug io.Writer) oauth2.TokenSource {
 	initCache(cacheBase)
-	cache := &tokenstore.CachedTokenSource{
-		Src:       src,
-		Store:     &tokenstore.FileStore{Path: filepath.Join(cacheBase, clientID)},
-		Refresher: refresher.New(tokenURL, clientID),
+	var opts []refresher.Option
+	if debug != nil {
+		opts = append(opts, refresher.UseHTTPClient(types.WithDebugLogger(nil, debug)))
 	}
+	cache := tokenstore.NewCachedTokenSource(
+		src,
+		(&tokenstore.FileStore{Path: filepath.Join(expandCachePath(cacheBase), key)}).WithCreateDirs(true),
+		refresher.New(tokenURL, clientID, opts...),
+	)
 
 	return oauth2.ReuseTokenSource(nil, cache)
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"golang.org/x/oauth2"
+
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/clientcreds"
+	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+func addClientCreds(cmd *cobra.Command) {
+	var cachePath string
+	var noCache bool
+	var clientID string
+	var issuerURI string
+	var clientSecret string
+
+	scopes := []string{}
+
+	clientCreds := &cobra.Command{
+		Use:   "client-creds",
+		Short: "Get oauth2 access token by using the client credentials grant (RFC6749)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if clientSecret == "" {
+				clientSecret = os.Getenv("OTOKEN_SECRET")
+			}
+			if clientSecret == "" {
+				return errors.New("client-secret is required")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint, err := openid.Discover(discoverContext(cmd), issuerURI)
+			if err != nil {
+				return err
+			}
+			var src oauth2.TokenSource
+
+			var opts []clientcreds.Option
+
+			if w := debugWriter(cmd); w != nil {
+				opts = append(opts, clientcreds.UseHTTPClient(types.WithDebugLogger(nil, w)))
+			}
+
+			src = clientcreds.NewTokenSource(endpoint.TokenURL, clientID, clientSecret, scopes, opts...)
+
+			if !noCache {
+				key := scopedCacheKey(clientID, scopes)
+				src = cachedSourceWithKey(src, endpoint.TokenURL, clientID, key, cachePath, debugWriter(cmd))
+			}
+
+			token, err := src.Token()
+			if err != nil {
+				return err
+			}
+			data, _ := json.MarshalIndent(token, "", "    ")
+			cmd.Print(string(data))
+			return nil
+		},
+	}
+	clientCreds.Flags().StringVarP(&cachePath, "store", "s", "~/.otoken", "path to store the token")
+	// nolint:errcheck
+	clientCreds.MarkFlagDirname("store")
+	clientCreds.Flags().BoolVar(&noCache, "no-cache", false, "flag to avoid the token cache")
+	clientCreds.MarkFlagsMutuallyExclusive("store", "no-cache")
+
+	clientCreds.Flags().StringVarP(&clientID, "client-id", "c", "", "OAuth2 client ID")
+	clientCreds.Flags().StringVarP(&issuerURI, "issuer", "i", "", "OAuth2 issuer URI")
+	// nolint:errcheck
+	clientCreds.MarkFlagRequired("client-id")
+	// nolint:errcheck
+	clientCreds.MarkFlagRequired("issuer")
+	clientCreds.Flags().StringVarP(&clientSecret, "client-secret", "p", "", "OAuth2 client secret, if empty, will use env $OTOKEN_SECRET")
+
+	clientCreds.Flags().StringArrayVar(&scopes, "scopes", []string{}, "scope used to request new token")
+
+	cmd.AddCommand(clientCreds)
+}
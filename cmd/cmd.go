@@ -1,18 +1,67 @@
 package cmd
 
 import (
+	"context"
+	"io"
+	"os"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
 	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/types"
 )
 
+// Version is the version of otoken, it's set at build time by
+// -ldflags "-X github.com/tiewei/otoken/cmd.Version=<version>"
+var Version = "dev"
+
+// userAgent is the default `User-Agent` header of http requests.
+func userAgent() string {
+	return "otoken/" + Version
+}
+
+// debugWriter returns where to dump http requests and responses,
+// it's nil unless the verbose flag is set.
+func debugWriter(cmd *cobra.Command) io.Writer {
+	if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+		return os.Stderr
+	}
+	return nil
+}
+
+// discoverContext returns the context used to discover endpoints,
+// which dumps the discovery request and response in verbose mode.
+func discoverContext(cmd *cobra.Command) context.Context {
+	if w := debugWriter(cmd); w != nil {
+		return gooidc.ClientContext(cmd.Context(), types.WithDebugLogger(nil, w))
+	}
+	return cmd.Context()
+}
+
 func New() *cobra.Command {
 
 	otoken := &cobra.Command{
-		Use:   "otoken",
-		Short: "otken is a cli to get oauth2 access token",
+		Use:     "otoken",
+		Short:   "otken is a cli to get oauth2 access token",
+		Version: Version,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if check, _ := cmd.Flags().GetBool("check-update"); check {
+				checkUpdate(cmd)
+			}
+		},
 	}
 
+	otoken.PersistentFlags().BoolP("verbose", "v", false, "log http requests and responses to stderr")
+	otoken.PersistentFlags().Bool("check-update", false, "check if a newer version of otoken is available")
+
 	addAppAuth(otoken)
+	addClientCreds(otoken)
 	addDevAuth(otoken)
+	addExchange(otoken)
+	addJWTBearer(otoken)
+	addPassAuth(otoken)
+	addListTokens(otoken)
+	addPrint(otoken)
+	addTokenInfo(otoken)
 
 	return otoken
 }
@@ -25,13 +25,20 @@ func addDevAuth(cmd *cobra.Command) {
 		Use:   "dev-auth",
 		Short: "Get oauth2 access token by using the device authorization (RFC8628)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			endpoint, err := openid.Discover(cmd.Context(), issuerURI)
+			endpoint, err := openid.Discover(discoverContext(cmd), issuerURI)
 			if err != nil {
 				return err
 			}
 			var src oauth2.TokenSource
 
-			var opts []devauth.Option
+			opts := []devauth.Option{
+				devauth.UseEndpoint(endpoint),
+				devauth.UseUserAgent(userAgent()),
+			}
+
+			if w := debugWriter(cmd); w != nil {
+				opts = append(opts, devauth.UseHTTPDebugLogger(w))
+			}
 
 			if noBrowser {
 				opts = append(opts, devauth.UseURLOpener(types.PromptOpener(types.StdoutPrompter)))
@@ -40,7 +47,7 @@ func addDevAuth(cmd *cobra.Command) {
 			src = devauth.NewTokenSource(endpoint.DeviceAuthURL, endpoint.TokenURL, clientID, scopes, opts...)
 
 			if !noCache {
-				src = cachedSource(src, endpoint.TokenURL, clientID, cachePath)
+				src = cachedSource(src, endpoint.TokenURL, clientID, cachePath, debugWriter(cmd))
 			}
 
 			token, err := src.Token()
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/tokenexchange"
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+func addExchange(cmd *cobra.Command) {
+	var clientID string
+	var issuerURI string
+	var clientSecret string
+	var subjectToken string
+	var subjectTokenType string
+	var requestedTokenType string
+
+	scopes := []string{}
+
+	exchange := &cobra.Command{
+		Use:   "exchange",
+		Short: "Exchange a token for an oauth2 access token by using the token exchange grant (RFC8693)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if clientSecret == "" {
+				clientSecret = os.Getenv("OTOKEN_SECRET")
+			}
+			if subjectToken == "" {
+				subjectToken = os.Getenv("OTOKEN_SUBJECT_TOKEN")
+			}
+			if subjectToken == "" {
+				return errors.New("subject-token is required")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint, err := openid.Discover(discoverContext(cmd), issuerURI)
+			if err != nil {
+				return err
+			}
+
+			var opts []tokenexchange.Option
+
+			if clientSecret != "" {
+				opts = append(opts, tokenexchange.UseClientSecret(clientSecret))
+			}
+
+			if requestedTokenType != "" {
+				opts = append(opts, tokenexchange.UseRequestedTokenType(requestedTokenType))
+			}
+
+			if w := debugWriter(cmd); w != nil {
+				opts = append(opts, tokenexchange.UseHTTPClient(types.WithDebugLogger(nil, w)))
+			}
+
+			if len(scopes) > 0 {
+				opts = append(opts, tokenexchange.UseScopes(scopes))
+			}
+
+			src := tokenexchange.New(endpoint.TokenURL, clientID, subjectToken, subjectTokenType, opts...)
+
+			token, err := src.Token()
+			if err != nil {
+				return err
+			}
+			data, _ := json.MarshalIndent(token, "", "    ")
+			cmd.Print(string(data))
+			return nil
+		},
+	}
+
+	exchange.Flags().StringVarP(&clientID, "client-id", "c", "", "OAuth2 client ID")
+	exchange.Flags().StringVarP(&issuerURI, "issuer", "i", "", "OAuth2 issuer URI")
+	// nolint:errcheck
+	exchange.MarkFlagRequired("client-id")
+	// nolint:errcheck
+	exchange.MarkFlagRequired("issuer")
+	exchange.Flags().StringVarP(&clientSecret, "client-secret", "p", "", "OAuth2 client secret, if empty, will use env $OTOKEN_SECRET")
+
+	exchange.Flags().StringVar(&subjectToken, "subject-token", "", "The token to be exchanged, if empty, will use env $OTOKEN_SUBJECT_TOKEN")
+	exchange.Flags().StringVar(&subjectTokenType, "subject-token-type", tokenexchange.AccessTokenType, "The type of the subject token")
+	exchange.Flags().StringVar(&requestedTokenType, "requested-token-type", "", "The type of the requested token, if empty, the authorization server decides")
+	exchange.Flags().StringArrayVar(&scopes, "scopes", []string{}, "scope used to request new token")
+
+	cmd.AddCommand(exchange)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+
+	"golang.org/x/oauth2"
+
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/jwtbearer"
+	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+func addJWTBearer(cmd *cobra.Command) {
+	var cachePath string
+	var noCache bool
+	var clientID string
+	var issuerURI string
+	var keyFile string
+	var subject string
+
+	scopes := []string{}
+
+	jwtBearer := &cobra.Command{
+		Use:   "jwt-bearer",
+		Short: "Get oauth2 access token by using the JWT bearer grant (RFC7523)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint, err := openid.Discover(discoverContext(cmd), issuerURI)
+			if err != nil {
+				return err
+			}
+			var src oauth2.TokenSource
+
+			opts := []jwtbearer.Option{
+				jwtbearer.UseScopes(scopes),
+			}
+
+			if w := debugWriter(cmd); w != nil {
+				opts = append(opts, jwtbearer.UseHTTPClient(types.WithDebugLogger(nil, w)))
+			}
+
+			src, err = jwtbearer.NewTokenSourceFromKeyFile(endpoint.TokenURL, clientID, expandCachePath(keyFile), subject, opts...)
+			if err != nil {
+				return err
+			}
+
+			if !noCache {
+				key := jwtBearerCacheKey(clientID, subject, scopes)
+				src = cachedSourceWithKey(src, endpoint.TokenURL, clientID, key, cachePath, debugWriter(cmd))
+			}
+
+			token, err := src.Token()
+			if err != nil {
+				return err
+			}
+			data, _ := json.MarshalIndent(token, "", "    ")
+			cmd.Print(string(data))
+			return nil
+		},
+	}
+	jwtBearer.Flags().StringVarP(&cachePath, "store", "s", "~/.otoken", "path to store the token")
+	// nolint:errcheck
+	jwtBearer.MarkFlagDirname("store")
+	jwtBearer.Flags().BoolVar(&noCache, "no-cache", false, "flag to avoid the token cache")
+	jwtBearer.MarkFlagsMutuallyExclusive("store", "no-cache")
+
+	jwtBearer.Flags().StringVarP(&clientID, "client-id", "c", "", "OAuth2 client ID")
+	jwtBearer.Flags().StringVarP(&issuerURI, "issuer", "i", "", "OAuth2 issuer URI")
+	jwtBearer.Flags().StringVarP(&keyFile, "key-file", "k", "", "PEM file of the RSA or ECDSA private key to sign the assertion")
+	jwtBearer.Flags().StringVar(&subject, "subject", "", "subject of the assertion, e.g. the service account")
+	// nolint:errcheck
+	jwtBearer.MarkFlagRequired("client-id")
+	// nolint:errcheck
+	jwtBearer.MarkFlagRequired("issuer")
+	// nolint:errcheck
+	jwtBearer.MarkFlagRequired("key-file")
+	// nolint:errcheck
+	jwtBearer.MarkFlagRequired("subject")
+	// nolint:errcheck
+	jwtBearer.MarkFlagFilename("key-file")
+
+	jwtBearer.Flags().StringArrayVar(&scopes, "scopes", []string{}, "scope used to request new token")
+
+	cmd.AddCommand(jwtBearer)
+}
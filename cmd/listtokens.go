@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/tokenstore"
+)
+
+func addListTokens(cmd *cobra.Command) {
+	var cachePath string
+
+	listTokens := &cobra.Command{
+		Use:   "list-tokens",
+		Short: "List tokens in the token cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lister := tokenstore.ListDir(expandCachePath(cachePath))
+			entries, err := lister.List()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "CLIENT ID\tVALID\tEXPIRY")
+			for _, entry := range entries {
+				expiry := "-"
+				if !entry.Token.Expiry.IsZero() {
+					expiry = entry.Token.Expiry.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%t\t%s\n", entry.Key, entry.Valid, expiry)
+			}
+			return w.Flush()
+		},
+	}
+	listTokens.Flags().StringVarP(&cachePath, "store", "s", "~/.otoken", "path to store the token")
+	// nolint:errcheck
+	listTokens.MarkFlagDirname("store")
+
+	cmd.AddCommand(listTokens)
+}
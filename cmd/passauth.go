@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/term"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/passauth"
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+// promptUsername prompts user to input the username.
+func promptUsername(prompter types.Prompter) (string, error) {
+	prompter("Username:", false)
+	reader := bufio.NewReader(os.Stdin)
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read username: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptPassword prompts user to input the password without echo.
+func promptPassword(prompter types.Prompter) (string, error) {
+	prompter("Password:", false)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(password), nil
+}
+
+// promptCredentials prompts user to input the username and password
+// which are empty.
+func promptCredentials(prompter types.Prompter, username string, password string) (string, string, error) {
+	var err error
+	if username == "" {
+		if username, err = promptUsername(prompter); err != nil {
+			return "", "", err
+		}
+	}
+	if password == "" {
+		if password, err = promptPassword(prompter); err != nil {
+			return "", "", err
+		}
+	}
+	return username, password, nil
+}
+
+func addPassAuth(cmd *cobra.Command) {
+	var cachePath string
+	var noCache bool
+	var clientID string
+	var issuerURI string
+	var clientSecret string
+	var username string
+	var password string
+
+	scopes := []string{}
+
+	passAuth := &cobra.Command{
+		Use:   "password-auth",
+		Short: "Get oauth2 access token by using the resource owner password credentials grant (RFC6749)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if clientSecret == "" {
+				clientSecret = os.Getenv("OTOKEN_SECRET")
+			}
+			if username == "" {
+				username = os.Getenv("OTOKEN_USERNAME")
+			}
+			if password == "" {
+				password = os.Getenv("OTOKEN_PASSWORD")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint, err := openid.Discover(discoverContext(cmd), issuerURI)
+			if err != nil {
+				return err
+			}
+			var src oauth2.TokenSource
+
+			opts := []passauth.Option{
+				passauth.UseCredentialsFunc(func() (string, string, error) {
+					return promptCredentials(types.StdoutPrompter, username, password)
+				}),
+			}
+
+			if w := debugWriter(cmd); w != nil {
+				opts = append(opts, passauth.UseHTTPClient(types.WithDebugLogger(nil, w)))
+			}
+
+			src = passauth.NewTokenSource(endpoint.TokenURL, clientID, clientSecret, username, password, scopes, opts...)
+
+			if !noCache {
+				// the prompted username is unknown before the cache lookup
+				key := passwordCacheKey(clientID, username)
+				src = cachedSourceWithKey(src, endpoint.TokenURL, clientID, key, cachePath, debugWriter(cmd))
+			}
+
+			token, err := src.Token()
+			if err != nil {
+				return err
+			}
+			data, _ := json.MarshalIndent(token, "", "    ")
+			cmd.Print(string(data))
+			return nil
+		},
+	}
+	passAuth.Flags().StringVarP(&cachePath, "store", "s", "~/.otoken", "path to store the token")
+	// nolint:errcheck
+	passAuth.MarkFlagDirname("store")
+	passAuth.Flags().BoolVar(&noCache, "no-cache", false, "flag to avoid the token cache")
+	passAuth.MarkFlagsMutuallyExclusive("store", "no-cache")
+
+	passAuth.Flags().StringVarP(&clientID, "client-id", "c", "", "OAuth2 client ID")
+	passAuth.Flags().StringVarP(&issuerURI, "issuer", "i", "", "OAuth2 issuer URI")
+	// nolint:errcheck
+	passAuth.MarkFlagRequired("client-id")
+	// nolint:errcheck
+	passAuth.MarkFlagRequired("issuer")
+	passAuth.Flags().StringVarP(&clientSecret, "client-secret", "p", "", "OAuth2 client secret (optional), if empty, will use env $OTOKEN_SECRET")
+
+	passAuth.Flags().StringVarP(&username, "username", "u", "", "resource owner username, if empty, will use env $OTOKEN_USERNAME or prompt when a new token is required")
+	passAuth.Flags().StringVar(&password, "password", "", "resource owner password, if empty, will use env $OTOKEN_PASSWORD or prompt when a new token is required")
+
+	passAuth.Flags().StringArrayVar(&scopes, "scopes", []string{gooidc.ScopeOpenID, gooidc.ScopeOfflineAccess}, "scope used to request new token")
+
+	cmd.AddCommand(passAuth)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/tokenstore"
+)
+
+func addPrint(cmd *cobra.Command) {
+	var cachePath string
+	var clientID string
+	var issuerURI string
+	var field string
+
+	printToken := &cobra.Command{
+		Use:   "print",
+		Short: "Print a field of the cached token",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store := &tokenstore.FileStore{Path: filepath.Join(expandCachePath(cachePath), clientID)}
+			token, err := store.Token()
+			if err != nil {
+				return fmt.Errorf("failed to read cached token: %w", err)
+			}
+			if !token.Valid() {
+				return errors.New("cached token is expired")
+			}
+			idToken, _ := token.Extra("id_token").(string)
+			if idToken != "" {
+				claims := struct {
+					Issuer string `json:"iss"`
+				}{}
+				if err := openid.ParseTokenClaims(idToken, &claims); err == nil && strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(issuerURI, "/") {
+					return fmt.Errorf("cached token is issued by %s", claims.Issuer)
+				}
+			}
+			var value string
+			switch field {
+			case "access_token":
+				value = token.AccessToken
+			case "refresh_token":
+				value = token.RefreshToken
+			case "id_token":
+				value = idToken
+			default:
+				return fmt.Errorf("unknown field %s", field)
+			}
+			if value == "" {
+				return fmt.Errorf("no %s in cached token", field)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	}
+	printToken.Flags().StringVarP(&cachePath, "store", "s", "~/.otoken", "path to store the token")
+	// nolint:errcheck
+	printToken.MarkFlagDirname("store")
+
+	printToken.Flags().StringVarP(&clientID, "client-id", "c", "", "OAuth2 client ID")
+	printToken.Flags().StringVarP(&issuerURI, "issuer", "i", "", "OAuth2 issuer URI")
+	// nolint:errcheck
+	printToken.MarkFlagRequired("client-id")
+	// nolint:errcheck
+	printToken.MarkFlagRequired("issuer")
+
+	printToken.Flags().StringVar(&field, "field", "access_token", "field to print, one of access_token, refresh_token and id_token")
+
+	cmd.AddCommand(printToken)
+}
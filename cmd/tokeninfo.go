@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/introspect"
+	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/tokenstore"
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+var errTokenInactive = errors.New("token is not active")
+
+func addTokenInfo(cmd *cobra.Command) {
+	var cachePath string
+	var clientID string
+	var issuerURI string
+	var clientSecret string
+	var rawToken string
+	var tokenTypeHint string
+	var scopes []string
+	var subject string
+	var username string
+
+	tokenInfo := &cobra.Command{
+		Use:   "token-info",
+		Short: "Introspect a token (RFC7662) and print its metadata",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if clientSecret == "" {
+				clientSecret = os.Getenv("OTOKEN_SECRET")
+			}
+			if clientSecret == "" {
+				return errors.New("client-secret is required")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the cached token is saved under the key of the command requested it
+			key := clientID
+			switch {
+			case username != "":
+				key = passwordCacheKey(clientID, username)
+			case subject != "":
+				key = jwtBearerCacheKey(clientID, subject, scopes)
+			case cmd.Flags().Changed("scopes"):
+				key = scopedCacheKey(clientID, scopes)
+			}
+			token, err := introspectedToken(cmd, rawToken, cachePath, key, tokenTypeHint)
+			if err != nil {
+				return err
+			}
+			endpoint, err := openid.Discover(discoverContext(cmd), issuerURI)
+			if err != nil {
+				return err
+			}
+			if endpoint.IntrospectionURL == "" {
+				return fmt.Errorf("issuer %s has no introspection endpoint", issuerURI)
+			}
+
+			// falls back to Basic auth if the provider supports neither
+			authMethod := openid.SelectIntrospectionAuthMethod(endpoint, []string{introspect.AuthMethodBasic, introspect.AuthMethodPost})
+			opts := []introspect.Option{
+				introspect.UseTokenTypeHint(tokenTypeHint),
+				introspect.UseAuthMethod(authMethod),
+			}
+			if w := debugWriter(cmd); w != nil {
+				opts = append(opts, introspect.UseHTTPClient(types.WithDebugLogger(nil, w)))
+			}
+			result, err := introspect.New(endpoint.IntrospectionURL, clientID, clientSecret, opts...).Introspect(cmd.Context(), token)
+			if err != nil {
+				return err
+			}
+			data, _ := json.MarshalIndent(result, "", "    ")
+			cmd.Print(string(data))
+			if !result.Active {
+				// the command is used correctly, hence skips the usage
+				cmd.SilenceUsage = true
+				return errTokenInactive
+			}
+			return nil
+		},
+	}
+	tokenInfo.Flags().StringVarP(&cachePath, "store", "s", "~/.otoken", "path to store the token")
+	// nolint:errcheck
+	tokenInfo.MarkFlagDirname("store")
+
+	tokenInfo.Flags().StringVarP(&clientID, "client-id", "c", "", "OAuth2 client ID")
+	tokenInfo.Flags().StringVarP(&issuerURI, "issuer", "i", "", "OAuth2 issuer URI")
+	// nolint:errcheck
+	tokenInfo.MarkFlagRequired("client-id")
+	// nolint:errcheck
+	tokenInfo.MarkFlagRequired("issuer")
+	tokenInfo.Flags().StringVarP(&clientSecret, "client-secret", "p", "", "OAuth2 client secret, if empty, will use env $OTOKEN_SECRET")
+
+	tokenInfo.Flags().StringVar(&rawToken, "token", "", "token to introspect, - to read from stdin, if empty, will use the cached token")
+	tokenInfo.Flags().StringVar(&tokenTypeHint, "token-type-hint", "access_token", "type of the token, one of access_token and refresh_token")
+
+	tokenInfo.Flags().StringArrayVar(&scopes, "scopes", []string{}, "scopes of the cached client-credentials or jwt-bearer token, use --scopes= for a client-credentials token requested without scopes")
+	tokenInfo.Flags().StringVar(&subject, "subject", "", "subject of the cached jwt-bearer token")
+	tokenInfo.Flags().StringVar(&username, "username", "", "username of the cached password token")
+	tokenInfo.MarkFlagsMutuallyExclusive("subject", "username")
+	tokenInfo.MarkFlagsMutuallyExclusive("token", "scopes")
+	tokenInfo.MarkFlagsMutuallyExclusive("token", "subject")
+	tokenInfo.MarkFlagsMutuallyExclusive("token", "username")
+
+	cmd.AddCommand(tokenInfo)
+}
+
+// introspectedToken returns the token to introspect, which is either rawToken,
+// read from stdin if rawToken is `-`, or read from the cache under key.
+func introspectedToken(cmd *cobra.Command, rawToken string, cachePath string, key string, tokenTypeHint string) (string, error) {
+	switch rawToken {
+	case "":
+	case "-":
+		raw, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return "", fmt.Errorf("failed to read token from stdin: %w", err)
+		}
+		if token := strings.TrimSpace(string(raw)); token != "" {
+			return token, nil
+		}
+		return "", errors.New("no token in stdin")
+	default:
+		return rawToken, nil
+	}
+
+	store := &tokenstore.FileStore{Path: filepath.Join(expandCachePath(cachePath), key)}
+	cached, err := store.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to read cached token: %w", err)
+	}
+	token := cached.AccessToken
+	if tokenTypeHint == "refresh_token" {
+		token = cached.RefreshToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("no %s in cached token", tokenTypeHint)
+	}
+	return token, nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+const (
+	latestReleaseURL   = "https://api.github.com/repos/tiewei/otoken/releases/latest"
+	checkUpdateTimeout = 2 * time.Second
+)
+
+// buildVersion returns the version of the running binary, it prefers the
+// version set by ldflags and falls back to the module version embedded by
+// `go install`, it's empty if neither is known.
+func buildVersion() string {
+	if Version != "dev" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return ""
+}
+
+// checkUpdate prints a notice to stderr if a newer release is available,
+// it fails silently on any error.
+func checkUpdate(cmd *cobra.Command) {
+	current := buildVersion()
+	if current == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), checkUpdateTimeout)
+	defer cancel()
+	latest, err := latestRelease(ctx)
+	if err != nil || !newerVersion(latest, current) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "A newer version of otoken is available: %s (current %s)\n", latest, current)
+}
+
+// latestRelease returns the tag of the latest GitHub release.
+func latestRelease(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := types.WithUserAgent(http.DefaultClient, userAgent()).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	release := struct {
+		TagName string `json:"tag_name"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+	return release.TagName, nil
+}
+
+// newerVersion reports whether version a is newer than b, both are
+// semantic versions with optional `v` prefix, pre-release and build
+// metadata are ignored.
+func newerVersion(a string, b string) bool {
+	va, vb := parseVersion(a), parseVersion(b)
+	if va == nil || vb == nil {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] > vb[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses major, minor and patch numbers of version.
+func parseVersion(version string) []int {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	numbers := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
@@ -12,17 +12,29 @@ package appauth
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/http/httptrace"
+	neturl "net/url"
+	"path"
 	"time"
 
+	"golang.org/x/crypto/ssh"
 	"golang.org/x/oauth2"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/int128/listener"
 	"github.com/int128/oauth2cli"
 	"github.com/int128/oauth2cli/oauth2params"
 	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/tokenstore"
 	"github.com/tiewei/otoken/pkg/types"
 )
 
@@ -78,13 +90,293 @@ func UseBindAddress(addresses []string) Option {
 // UseRedirectHostname provides a way to set redirect hostname.
 //
 // The RFC8252 requires 127.0.0.1 address to for safety reason.
-// You can set this if your provider does not accept 127.0.0.1
+// You can set this if your provider does not accept 127.0.0.1,
+// see UseLocalhostRedirect for the implications of `localhost`.
 func UseRedirectHostname(hostname string) Option {
 	return &option{applyFunc: func(s *TokenSource) {
 		s.redirectHostname = hostname
 	}}
 }
 
+// UseHTTPSRedirectURL configures the local server to serve TLS with the
+// provided certificate, and sets the redirect hostname to `localhost`
+// since the certificate usually won't contain 127.0.0.1 as SAN.
+//
+// Some providers require HTTPS redirect URL even for loopback,
+// GenerateSelfSignedCert can be used to create such certificate.
+func UseHTTPSRedirectURL(cert tls.Certificate) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.tlsCert = &cert
+		s.redirectHostname = "localhost"
+	}}
+}
+
+// UsePersistTo saves the token into store after it's received,
+// before returning it.
+func UsePersistTo(store tokenstore.Store) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.persistTo = store
+	}}
+}
+
+// UseFormPostResponseMode requests the authorization response to be
+// delivered by `response_mode=form_post`, the local server reads the
+// code from the POST body instead of the URL query.
+func UseFormPostResponseMode(enabled bool) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.formPost = enabled
+	}}
+}
+
+// UseExtraScopes appends scopes to the base scope list,
+// duplicated scopes are removed, hence it's safe to use multiple times.
+func UseExtraScopes(scopes ...string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.scopes = openid.MergeScopes(s.scopes, scopes...)
+	}}
+}
+
+// UseCallbackValidator validates the callback request before the code
+// is extracted from it. If the validator returns error, the callback is
+// rejected with 400 and the error is returned by Token().
+func UseCallbackValidator(validator func(r *http.Request) error) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.callbackValidator = validator
+	}}
+}
+
+// UseAuthStyle sets how the client authenticates with the token endpoint,
+// by default it's auto detected.
+func UseAuthStyle(style oauth2.AuthStyle) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.authStyle = style
+	}}
+}
+
+// UseSSHTunnel establishes an SSH reverse tunnel to the SSH server at remoteAddr,
+// which listens on 127.0.0.1:<remotePort> of the SSH server host and forwards
+// connections to the local callback server bound on 127.0.0.1:<localPort>.
+//
+// This is useful when running on a remote machine while the browser is on the
+// SSH server host. Since the redirect URL is built from the callback server port,
+// localPort and remotePort are usually the same.
+func UseSSHTunnel(localPort int, remotePort int, sshConfig *ssh.ClientConfig, remoteAddr string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.sshTunnel = &sshTunnel{
+			localPort:  localPort,
+			remotePort: remotePort,
+			config:     sshConfig,
+			remoteAddr: remoteAddr,
+		}
+		s.bindAddresses = []string{fmt.Sprintf("127.0.0.1:%d", localPort)}
+	}}
+}
+
+// UseAuthorizationTimer prints how long until the authorization request
+// expires to w every 10 seconds, until the token is received or it expires.
+func UseAuthorizationTimer(expiresIn time.Duration, w io.Writer) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.timerExpiresIn = expiresIn
+		s.timerWriter = w
+	}}
+}
+
+// UseEndpoint sets the discovered provider metadata, which is used to
+// validate the provider capabilities.
+func UseEndpoint(endpoint *openid.Endpoint) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.endpoint = endpoint
+	}}
+}
+
+// ValidateCapabilities checks the provider supports the `code` response type
+// before starting the flow, it requires the metadata set by UseEndpoint.
+func ValidateCapabilities(enabled bool) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.validateCapabilities = enabled
+	}}
+}
+
+// UseAuthorizationDetails sets the `authorization_details` param of the
+// authorization request described in rfc9396.
+func UseAuthorizationDetails(details []json.RawMessage) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.authorizationDetails = details
+	}}
+}
+
+// UseAuthorizationDetailsInTokenRequest also sets the `authorization_details`
+// param set by UseAuthorizationDetails in the token request.
+func UseAuthorizationDetailsInTokenRequest(enabled bool) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.authorizationDetailsInToken = enabled
+	}}
+}
+
+// UseUserAgent sets `User-Agent` header on all http requests.
+func UseUserAgent(ua string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.userAgent = ua
+	}}
+}
+
+// UseServerReadyCallback sets the callback called with the address of
+// the local server once it's ready, before the URL opener is called.
+func UseServerReadyCallback(callback func(addr string)) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.serverReadyCallback = callback
+	}}
+}
+
+// UseAuthCodeOptions adds extra options to the authorization request.
+func UseAuthCodeOptions(opts ...oauth2.AuthCodeOption) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.authCodeOptions = append(s.authCodeOptions, opts...)
+	}}
+}
+
+// UseStrictCallbackParsing rejects the callback request to the local server
+// unless it's a GET request (after form_post conversion if enabled), with a
+// single non-empty `code`, and without both `code` and `error`.
+func UseStrictCallbackParsing(enabled bool) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.strictCallback = enabled
+	}}
+}
+
+// UseDisplay sets the OpenID Connect `display` parameter of the authorization
+// request, which hints how the provider renders the login page, one of
+// `page`, `popup`, `touch` and `wap`.
+func UseDisplay(mode string) Option {
+	return UseAuthCodeOptions(oauth2.SetAuthURLParam("display", mode))
+}
+
+// UseConsentPrompt sets `prompt=consent` to the authorization request if
+// enabled, which makes the provider ask the user to approve the requested
+// scopes on every login, instead of reusing consent of previous sessions.
+func UseConsentPrompt(enabled bool) Option {
+	if !enabled {
+		return nil
+	}
+	return UseAuthCodeOptions(oauth2.SetAuthURLParam("prompt", "consent"))
+}
+
+// UseDialTimeout sets timeout of establishing connections,
+// it's independent from the overall Timeout.
+func UseDialTimeout(d time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.dialTimeout = d
+	}}
+}
+
+// UseResponseTimeout sets timeout of each http request,
+// it's independent from the overall Timeout.
+func UseResponseTimeout(d time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.responseTimeout = d
+	}}
+}
+
+// WithAllowedStates allows callback requests with the given states in addition
+// to the generated one, e.g. when resuming a flow. Requests with other states
+// are rejected with 400.
+func WithAllowedStates(states ...string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.allowedStates = append(s.allowedStates, states...)
+	}}
+}
+
+// UseHTTPDebugLogger dumps all http requests and responses to w,
+// note the dump contains credentials like tokens.
+func UseHTTPDebugLogger(w io.Writer) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.debugWriter = w
+	}}
+}
+
+// ErrIssuerMismatch is returned when the `iss` claim of the ID token
+// doesn't match the expected issuer.
+var ErrIssuerMismatch = errors.New("issuer of id token mismatch")
+
+// UseIssuerValidation validates the `iss` claim of the received ID token
+// matches expectedIssuer, it guards against IdP confusion attacks.
+func UseIssuerValidation(expectedIssuer string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.expectedIssuer = expectedIssuer
+	}}
+}
+
+// UseExternalRedirectURI sets the redirect URI sent to the provider to uri,
+// while the local server still listens on the internal address. It's useful
+// when running behind a reverse proxy, which forwards uri to the local server.
+func UseExternalRedirectURI(uri string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.externalRedirectURI = uri
+	}}
+}
+
+// UseMTLSCertificate presents cert to the token endpoint for mutual TLS
+// client authentication described in rfc8705. Unlike UseHTTPClient,
+// it keeps the rest of the http client settings.
+func UseMTLSCertificate(cert tls.Certificate) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.mtlsCert = &cert
+	}}
+}
+
+// UseSlogLevelVar logs http requests at debug level to stderr, whether to
+// log is decided by levelVar, hence it can be adjusted at runtime.
+func UseSlogLevelVar(levelVar *slog.LevelVar) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.slogLevel = levelVar
+	}}
+}
+
+// UsePortRange binds the local server on a free port in [min, max] of
+// 127.0.0.1, ports are tried in random order, Token returns error wrapping
+// ErrNoAvailablePort if none of them is available. It overrides UseBindAddress.
+func UsePortRange(min int, max int) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.portMin = min
+		s.portMax = max
+	}}
+}
+
+// ErrNoAvailablePort is returned if no port in the range of UsePortRange is free.
+var ErrNoAvailablePort = errors.New("no available port in range")
+
+// UseTokenResponseLogger writes the raw request and response bodies of the
+// token endpoint to w before the response is parsed, `client_secret`,
+// `code_verifier` and the tokens are redacted, it's for debugging.
+func UseTokenResponseLogger(w io.Writer) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.tokenResponseWriter = w
+	}}
+}
+
+// UseHTTPTrace attaches tracer to every http request, e.g. to diagnose
+// DNS resolution or TLS handshake failures with the provider.
+func UseHTTPTrace(tracer *httptrace.ClientTrace) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.httpTrace = tracer
+	}}
+}
+
+// ErrRedirectURIMismatch is returned when the redirect URI doesn't match
+// the pattern set by UseRedirectURIPattern.
+var ErrRedirectURIMismatch = errors.New("redirect uri mismatch")
+
+// UseRedirectURIPattern validates the redirect URI against the glob pattern
+// before sending the authorization request, the syntax is the same as
+// path.Match, e.g. `http://127.0.0.1:*` or `https://*.example.com/callback`.
+// It applies to both the local server URL and the URI set by
+// UseExternalRedirectURI, Token returns ErrRedirectURIMismatch on mismatch.
+func UseRedirectURIPattern(pattern string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.redirectURIPattern = pattern
+	}}
+}
+
 type TokenSource struct {
 	authEndpoint  string
 	tokenEndpoint string
@@ -98,6 +390,43 @@ type TokenSource struct {
 	bindAddresses    []string
 	timeout          time.Duration
 	redirectHostname string
+	tlsCert          *tls.Certificate
+	persistTo        tokenstore.Store
+	formPost         bool
+
+	callbackValidator func(r *http.Request) error
+	authStyle         oauth2.AuthStyle
+	sshTunnel         *sshTunnel
+	timerExpiresIn    time.Duration
+	timerWriter       io.Writer
+
+	endpoint             *openid.Endpoint
+	validateCapabilities bool
+
+	authorizationDetails        []json.RawMessage
+	authorizationDetailsInToken bool
+	userAgent                   string
+	serverReadyCallback         func(addr string)
+	authCodeOptions             []oauth2.AuthCodeOption
+	dialTimeout                 time.Duration
+	responseTimeout             time.Duration
+	allowedStates               []string
+	debugWriter                 io.Writer
+	expectedIssuer              string
+	externalRedirectURI         string
+	hybrid                      bool
+	mtlsCert                    *tls.Certificate
+	slogLevel                   *slog.LevelVar
+	portMin                     int
+	portMax                     int
+	tokenResponseWriter         io.Writer
+	redirectURIPattern          string
+	httpTrace                   *httptrace.ClientTrace
+	exchangeAttempts            int
+	exchangeDelay               time.Duration
+	strictCallback              bool
+	requestURI                  string
+	tokenBinding                bool
 }
 
 var _ oauth2.TokenSource = &TokenSource{}
@@ -142,14 +471,84 @@ func NewImplicit(authEndpoint string, tokenEndpoint string, clientID string, cli
 	return s
 }
 
+// httpClient returns the http client configured by options.
+func (s *TokenSource) httpClient() *http.Client {
+	client := s.client
+	if s.mtlsCert != nil {
+		client = types.WithClientCertificate(client, *s.mtlsCert)
+	}
+	client = types.WithTimeouts(client, s.dialTimeout, s.responseTimeout)
+	client = types.WithHTTPTrace(client, s.httpTrace)
+	client = types.WithDebugLogger(client, s.debugWriter)
+	client = types.WithSlogLevelVar(client, s.slogLevel)
+	client = withTokenResponseLogger(client, s.tokenEndpoint, s.tokenResponseWriter)
+	client = withExchangeRetry(client, s.tokenEndpoint, s.exchangeAttempts, s.exchangeDelay)
+	return types.WithUserAgent(client, s.userAgent)
+}
+
+// validate checks the provider capabilities required by the flow.
+func (s *TokenSource) validate() error {
+	if err := s.validateRequestURI(); err != nil {
+		return err
+	}
+	if err := s.validateTokenBinding(); err != nil {
+		return err
+	}
+	if !s.validateCapabilities {
+		return nil
+	}
+	if s.endpoint == nil {
+		return errors.New("provider metadata is required to validate capabilities")
+	}
+	if !openid.SupportsResponseType(s.endpoint, "code") {
+		return errors.New("provider does not support response type code")
+	}
+	return nil
+}
+
+// NewFromOAuth2Config creates TokenSource from cfg, it uses PKCE flow if the
+// client secret is empty. The auth style of the endpoint is preserved, and the
+// redirect URL, if set, is used as the redirect hostname and bind address.
+// The options are applied after the fields extracted from cfg.
+func NewFromOAuth2Config(cfg oauth2.Config, opts ...Option) *TokenSource {
+	var cfgOpts []Option
+	if cfg.Endpoint.AuthStyle != oauth2.AuthStyleAutoDetect {
+		cfgOpts = append(cfgOpts, UseAuthStyle(cfg.Endpoint.AuthStyle))
+	}
+	if u, err := neturl.Parse(cfg.RedirectURL); err == nil && u.Hostname() != "" {
+		cfgOpts = append(cfgOpts, UseRedirectHostname(u.Hostname()))
+		if u.Port() != "" {
+			cfgOpts = append(cfgOpts, UseBindAddress([]string{u.Host}))
+		}
+	}
+	opts = append(cfgOpts, opts...)
+	if cfg.ClientSecret == "" {
+		return NewPKCE(cfg.Endpoint.AuthURL, cfg.Endpoint.TokenURL, cfg.ClientID, cfg.Scopes, opts...)
+	}
+	return NewImplicit(cfg.Endpoint.AuthURL, cfg.Endpoint.TokenURL, cfg.ClientID, cfg.ClientSecret, cfg.Scopes, opts...)
+}
+
 func (s *TokenSource) Token() (*oauth2.Token, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
+		defer cancelFunc()
+	}
+	if client := s.httpClient(); client != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+	}
 	oauth2Cfg := oauth2.Config{
 		ClientID:     s.clientID,
 		ClientSecret: s.clientSecret,
 		Scopes:       s.scopes,
 		Endpoint: oauth2.Endpoint{
-			TokenURL: s.tokenEndpoint,
-			AuthURL:  s.authEndpoint,
+			TokenURL:  s.tokenEndpoint,
+			AuthURL:   s.authEndpoint,
+			AuthStyle: s.authStyle,
 		},
 	}
 	readyChan := make(chan string, 1)
@@ -161,32 +560,125 @@ func (s *TokenSource) Token() (*oauth2.Token, error) {
 	if len(s.bindAddresses) > 0 {
 		config.LocalServerBindAddress = s.bindAddresses
 	}
+	var loopbacks []string
+	if s.redirectHostname == "localhost" && len(s.bindAddresses) == 0 {
+		addresses, err := localhostBindAddresses(ctx)
+		if err != nil {
+			return nil, err
+		}
+		config.LocalServerBindAddress = addresses[:1]
+		loopbacks = addresses[1:]
+	}
+	if s.portMin > 0 || s.portMax > 0 {
+		addresses, err := portRangeAddresses(s.portMin, s.portMax)
+		if err != nil {
+			return nil, err
+		}
+		config.LocalServerBindAddress = addresses
+	}
+	config.AuthCodeOptions = append(config.AuthCodeOptions, s.authCodeOptions...)
+	if s.externalRedirectURI != "" {
+		if err := s.matchRedirectURI(s.externalRedirectURI); err != nil {
+			return nil, err
+		}
+		param := oauth2.SetAuthURLParam("redirect_uri", s.externalRedirectURI)
+		config.AuthCodeOptions = append(config.AuthCodeOptions, param)
+		config.TokenRequestOptions = append(config.TokenRequestOptions, param)
+	}
+	if s.formPost {
+		config.AuthCodeOptions = append(config.AuthCodeOptions, oauth2.SetAuthURLParam("response_mode", "form_post"))
+	}
+	if s.requestURI != "" {
+		config.AuthCodeOptions = append(config.AuthCodeOptions, oauth2.SetAuthURLParam("request_uri", s.requestURI))
+	}
+	if len(s.authorizationDetails) > 0 {
+		details, err := json.Marshal(s.authorizationDetails)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorization details: %w", err)
+		}
+		param := oauth2.SetAuthURLParam("authorization_details", string(details))
+		config.AuthCodeOptions = append(config.AuthCodeOptions, param)
+		if s.authorizationDetailsInToken {
+			config.TokenRequestOptions = append(config.TokenRequestOptions, param)
+		}
+	}
+	if s.tlsCert != nil {
+		certFile, keyFile, cleanup, err := writeCertFiles(s.tlsCert)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		config.LocalServerCertFile = certFile
+		config.LocalServerKeyFile = keyFile
+	}
+	if s.sshTunnel != nil {
+		stop, err := s.sshTunnel.start()
+		if err != nil {
+			return nil, err
+		}
+		defer stop()
+	}
 	if s.usePKCE {
 		pkce, err := oauth2params.NewPKCE()
 		if err != nil {
 			return nil, err
 		}
-		config.AuthCodeOptions = pkce.AuthCodeOptions()
-		config.TokenRequestOptions = pkce.TokenRequestOptions()
+		config.AuthCodeOptions = append(config.AuthCodeOptions, pkce.AuthCodeOptions()...)
+		config.TokenRequestOptions = append(config.TokenRequestOptions, pkce.TokenRequestOptions()...)
 	}
-	ctx := context.Background()
-	if s.timeout > 0 {
-		var cancelFunc context.CancelFunc
-		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
-		defer cancelFunc()
+	ctx, reject := context.WithCancelCause(ctx)
+	defer reject(nil)
+	state, err := oauth2params.NewState()
+	if err != nil {
+		return nil, err
 	}
-	if s.client != nil {
-		ctx = context.WithValue(ctx, oauth2.HTTPClient, s.client)
+	config.State = state
+	middleware := s.localServerMiddleware(state, reject)
+	if s.hybrid {
+		nonce, opts, err := hybridOptions()
+		if err != nil {
+			return nil, err
+		}
+		config.AuthCodeOptions = append(config.AuthCodeOptions, opts...)
+		middleware = chainMiddlewares(middleware, hybridMiddleware(s.clientID, nonce, reject))
+	}
+	config.LocalServerMiddleware = middleware
+
+	if s.timerWriter != nil && s.timerExpiresIn > 0 {
+		stop := countdown(s.timerExpiresIn, s.timerWriter)
+		defer stop()
 	}
 
 	var eg errgroup.Group
 	var token *oauth2.Token
+	var stopLoopbacks func()
 	eg.Go(func() error {
 		select {
 		case url, ok := <-readyChan:
 			if !ok {
 				return nil
 			}
+			if len(loopbacks) > 0 && len(config.LocalServerBindAddress) > 0 {
+				stop, err := startLoopbacks(loopbacks, config.LocalServerBindAddress[0], url)
+				if err != nil {
+					reject(err)
+					return err
+				}
+				stopLoopbacks = stop
+			}
+			if s.serverReadyCallback != nil {
+				addr := url
+				if u, err := neturl.Parse(url); err == nil {
+					addr = u.Host
+				}
+				s.serverReadyCallback(addr)
+			}
+			if s.externalRedirectURI == "" {
+				if err := s.matchRedirectURI(url); err != nil {
+					reject(err)
+					return err
+				}
+			}
 			s.opener(url)
 			return nil
 		case <-ctx.Done():
@@ -197,12 +689,88 @@ func (s *TokenSource) Token() (*oauth2.Token, error) {
 		var err error
 		token, err = oauth2cli.GetToken(ctx, config)
 		if err != nil {
+			// stops waiting for the local server if it failed to start
+			reject(err)
 			return fmt.Errorf("could not get a token: %w", err)
 		}
 		return nil
 	})
-	if err := eg.Wait(); err != nil {
+	err = eg.Wait()
+	if stopLoopbacks != nil {
+		stopLoopbacks()
+	}
+	var noPortErr listener.NoAvailablePortError
+	if (s.portMin > 0 || s.portMax > 0) && errors.As(err, &noPortErr) {
+		return nil, fmt.Errorf("%w: %s", ErrNoAvailablePort, noPortErr)
+	}
+	if err != nil {
 		log.Printf("authorization error: %s", err)
 	}
+	if cause := context.Cause(ctx); errors.Is(cause, errCallbackRejected) || errors.Is(cause, ErrRedirectURIMismatch) {
+		return nil, cause
+	}
+	if token != nil && s.expectedIssuer != "" {
+		if err := validateIssuer(token, s.expectedIssuer); err != nil {
+			return nil, err
+		}
+	}
+	if token != nil && s.persistTo != nil {
+		if err := s.persistTo.Save(token); err != nil {
+			return nil, fmt.Errorf("could not persist the token: %w", err)
+		}
+	}
 	return token, nil
 }
+
+// matchRedirectURI checks uri against the pattern set by UseRedirectURIPattern.
+func (s *TokenSource) matchRedirectURI(uri string) error {
+	if s.redirectURIPattern == "" {
+		return nil
+	}
+	matched, err := path.Match(s.redirectURIPattern, uri)
+	if err != nil {
+		return fmt.Errorf("invalid redirect uri pattern: %w", err)
+	}
+	if !matched {
+		return fmt.Errorf("%w: %s doesn't match %s", ErrRedirectURIMismatch, uri, s.redirectURIPattern)
+	}
+	return nil
+}
+
+// portRangeAddresses returns loopback addresses of ports in [min, max] in random order.
+func portRangeAddresses(min int, max int) ([]string, error) {
+	if min <= 0 || max > 65535 || min > max {
+		return nil, fmt.Errorf("invalid port range [%d, %d]", min, max)
+	}
+	addresses := make([]string, 0, max-min+1)
+	for _, i := range rand.Perm(max - min + 1) {
+		addresses = append(addresses, fmt.Sprintf("127.0.0.1:%d", min+i))
+	}
+	return addresses, nil
+}
+
+// validateIssuer checks the `iss` claim of the ID token in token.
+func validateIssuer(token *oauth2.Token, expectedIssuer string) error {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return errors.New("no id token in the token response")
+	}
+	claims := struct {
+		Issuer string `json:"iss"`
+	}{}
+	if err := openid.ParseTokenClaims(rawIDToken, &claims); err != nil {
+		return err
+	}
+	if claims.Issuer != expectedIssuer {
+		return fmt.Errorf("%w: expected %s, got %s", ErrIssuerMismatch, expectedIssuer, claims.Issuer)
+	}
+	return nil
+}
+
+// Endpoint returns the endpoints the TokenSource is configured to use.
+func (s *TokenSource) Endpoint() openid.Endpoint {
+	return openid.Endpoint{
+		TokenURL: s.tokenEndpoint,
+		AuthURL:  s.authEndpoint,
+	}
+}
@@ -0,0 +1,30 @@
+package appauth
+
+import (
+	"github.com/tiewei/otoken/pkg/types"
+	"golang.org/x/oauth2"
+)
+
+// AppToken is the token received by TokenSource along with the raw
+// ID token, IDToken is empty if the provider doesn't return one.
+type AppToken struct {
+	*oauth2.Token
+	IDToken string
+}
+
+// IsDPoP reports whether the token is a DPoP-bound access token (rfc9449),
+// which requires a DPoP proof on resource requests.
+func (t *AppToken) IsDPoP() bool {
+	return types.IsDPoP(t.Token)
+}
+
+// AppToken is the same as Token, but also returns the raw ID token
+// in the token response.
+func (s *TokenSource) AppToken() (*AppToken, error) {
+	token, err := s.Token()
+	if err != nil {
+		return nil, err
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	return &AppToken{Token: token, IDToken: idToken}, nil
+}
@@ -0,0 +1,39 @@
+package appauth
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const countdownInterval = 10 * time.Second
+
+// countdown prints the remaining time of the authorization request to w
+// every 10 seconds until it expires, it returns the function to stop it.
+func countdown(expiresIn time.Duration, w io.Writer) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		deadline := time.Now().Add(expiresIn)
+		ticker := time.NewTicker(countdownInterval)
+		defer ticker.Stop()
+		for {
+			remaining := time.Until(deadline).Round(time.Second)
+			if remaining <= 0 {
+				fmt.Fprintln(w, "Authorization expired")
+				return
+			}
+			fmt.Fprintf(w, "Authorization expires in %ds\n", int(remaining.Seconds()))
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
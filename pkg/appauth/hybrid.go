@@ -0,0 +1,125 @@
+package appauth
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"golang.org/x/oauth2"
+
+	"github.com/int128/oauth2cli/oauth2params"
+	"github.com/tiewei/otoken/pkg/openid"
+)
+
+// NewHybrid creates TokenSource uses the OpenID Connect hybrid flow with
+// `response_type=code id_token`. The ID token in the authorization response
+// is validated in the callback before the code is exchanged for the tokens.
+//
+// The authorization response is delivered by `response_mode=form_post`, since
+// the local server can't read the default fragment encoded response.
+func NewHybrid(authEndpoint string, tokenEndpoint string, clientID string, scopes []string, opts ...Option) *TokenSource {
+	s := NewPKCE(authEndpoint, tokenEndpoint, clientID, scopes, opts...)
+	s.hybrid = true
+	s.formPost = true
+	return s
+}
+
+// hybridOptions returns the authorization request options of the hybrid flow.
+func hybridOptions() (nonce string, opts []oauth2.AuthCodeOption, err error) {
+	nonce, err = oauth2params.NewState()
+	if err != nil {
+		return "", nil, err
+	}
+	return nonce, []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("response_type", "code id_token"),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	}, nil
+}
+
+// hybridMiddleware validates the ID token of the callback request.
+func hybridMiddleware(clientID string, nonce string, reject func(error)) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if isCallback(r) && q.Get("code") != "" {
+				if err := validateHybridIDToken(q.Get("id_token"), q.Get("code"), clientID, nonce); err != nil {
+					http.Error(w, "invalid id token", http.StatusBadRequest)
+					reject(fmt.Errorf("%w: %w", errCallbackRejected, err))
+					return
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validateHybridIDToken validates claims of the ID token in the authorization
+// response, see https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken
+//
+// The signature is not verified, the claims are only used to bind the
+// authorization response to this request.
+func validateHybridIDToken(rawIDToken string, code string, clientID string, nonce string) error {
+	if rawIDToken == "" {
+		return errors.New("no id token in the authorization response")
+	}
+	claims := struct {
+		Audience openid.Audience `json:"aud"`
+		Expiry   int64           `json:"exp"`
+		Nonce    string          `json:"nonce"`
+		CodeHash string          `json:"c_hash"`
+	}{}
+	if err := openid.ParseTokenClaims(rawIDToken, &claims); err != nil {
+		return err
+	}
+	if claims.Nonce != nonce {
+		return errors.New("nonce of id token mismatch")
+	}
+	if !claims.Audience.Contains(clientID) {
+		return fmt.Errorf("id token is not issued to client %s", clientID)
+	}
+	if time.Unix(claims.Expiry, 0).Before(time.Now()) {
+		return errors.New("id token is expired")
+	}
+	if claims.CodeHash == "" {
+		return errors.New("no c_hash in id token")
+	}
+	expected, err := codeHash(rawIDToken, code)
+	if err != nil {
+		return err
+	}
+	if claims.CodeHash != expected {
+		return errors.New("c_hash of id token mismatch")
+	}
+	return nil
+}
+
+// codeHash computes the c_hash of code, it's the left half of the hash of
+// code, where the hash function is the one used by the `alg` of the ID
+// token JWS header, e.g. SHA-384 for RS384, and SHA-512 for EdDSA (Ed25519).
+func codeHash(rawIDToken string, code string) (string, error) {
+	jws, err := jose.ParseSigned(rawIDToken)
+	if err != nil {
+		return "", fmt.Errorf("malformed id token: %w", err)
+	}
+	var h hash.Hash
+	switch alg := jws.Signatures[0].Header.Algorithm; {
+	case strings.HasSuffix(alg, "256"):
+		h = sha256.New()
+	case strings.HasSuffix(alg, "384"):
+		h = sha512.New384()
+	case strings.HasSuffix(alg, "512"), alg == string(jose.EdDSA):
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported id token algorithm %q to compute c_hash", alg)
+	}
+	h.Write([]byte(code))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
@@ -0,0 +1,126 @@
+package appauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+)
+
+// UseLocalhostRedirect uses `localhost` instead of `127.0.0.1` as the redirect
+// hostname, for browsers or providers reject `127.0.0.1` in redirect URL.
+//
+// RFC8252 section 8.3 recommends `127.0.0.1` because `localhost` can be
+// resolved to a non-loopback address by a modified hosts file or DNS, which
+// leaks the authorization code, and browsers may resolve it to `::1` while
+// the local server only listens on `127.0.0.1`. Hence unless UseBindAddress
+// is set, Token resolves `localhost` before starting the local server, and
+// fails if it resolves to any non-loopback address, then listens on every
+// resolved address so the browser can reach it over both IPv4 and IPv6.
+func UseLocalhostRedirect() Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.redirectHostname = "localhost"
+	}}
+}
+
+// localhostBindAddresses resolves `localhost` and returns every loopback
+// address it resolves to, IPv4 first to be compatible with the default
+// `127.0.0.1` binding. The local server binds on the first address, and
+// startLoopbacks forwards the others to it, so the browser can reach the
+// server whichever address it picks.
+func localhostBindAddresses(ctx context.Context) ([]string, error) {
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", "localhost")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve localhost: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("localhost resolves to no address")
+	}
+	var v4, v6 []string
+	seen := map[string]bool{}
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return nil, fmt.Errorf("localhost resolves to non-loopback address %s", ip)
+		}
+		if seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		address := net.JoinHostPort(ip.String(), "0")
+		if ip.To4() != nil {
+			v4 = append(v4, address)
+		} else {
+			v6 = append(v6, address)
+		}
+	}
+	return append(v4, v6...), nil
+}
+
+// startLoopbacks listens on every address in addresses with the port of the
+// local server at serverURL, and forwards connections to the server bound on
+// bindAddress, it returns the function to stop listening.
+func startLoopbacks(addresses []string, bindAddress string, serverURL string) (func(), error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local server url: %w", err)
+	}
+	port := u.Port()
+	bindHost, _, err := net.SplitHostPort(bindAddress)
+	if err != nil {
+		return nil, err
+	}
+	target := net.JoinHostPort(bindHost, port)
+	var listeners []net.Listener
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := map[net.Conn]bool{}
+	stop := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+		// close kept-alive browser connections, they never end by themselves
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	}
+	for _, address := range addresses {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("could not start a local server on %s: %w", host, err)
+		}
+		listeners = append(listeners, l)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				conns[conn] = true
+				mu.Unlock()
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					forward(conn, target)
+					mu.Lock()
+					delete(conns, conn)
+					mu.Unlock()
+				}()
+			}
+		}()
+	}
+	return stop, nil
+}
@@ -0,0 +1,195 @@
+package appauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+var errCallbackRejected = errors.New("callback request rejected")
+
+// formPostMiddleware converts the authorization response delivered by
+// `response_mode=form_post` into a GET request with query params,
+// which is what the local server handler expects.
+func formPostMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form post", http.StatusBadRequest)
+			return
+		}
+		u := *r.URL
+		u.RawQuery = r.PostForm.Encode()
+		req := r.Clone(r.Context())
+		req.Method = http.MethodGet
+		req.URL = &u
+		req.Body = http.NoBody
+		req.ContentLength = 0
+		h.ServeHTTP(w, req)
+	})
+}
+
+// isCallback reports whether r is the authorization response callback.
+func isCallback(r *http.Request) bool {
+	q := r.URL.Query()
+	return r.Method == http.MethodGet && r.URL.Path == "/" && (q.Get("code") != "" || q.Get("error") != "")
+}
+
+// callbackValidatorMiddleware rejects the callback request with 400 if the
+// validator fails, and reports the error by reject.
+func callbackValidatorMiddleware(validator func(r *http.Request) error, reject func(error)) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isCallback(r) {
+				if err := validator(r); err != nil {
+					http.Error(w, "invalid callback request", http.StatusBadRequest)
+					reject(fmt.Errorf("%w: %w", errCallbackRejected, err))
+					return
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// strictCallbackMiddleware rejects the requests to `/` which are not GET,
+// and callback requests with empty or duplicated `code`, or with both
+// `code` and `error`.
+func strictCallbackMiddleware(reject func(error)) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if err := checkStrictCallback(r); err != nil {
+				http.Error(w, "invalid callback request", http.StatusBadRequest)
+				reject(fmt.Errorf("%w: %w", errCallbackRejected, err))
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkStrictCallback checks the request to `/` is a valid callback.
+func checkStrictCallback(r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return fmt.Errorf("unexpected method %s", r.Method)
+	}
+	q := r.URL.Query()
+	codes, hasCode := q["code"]
+	_, hasError := q["error"]
+	if hasCode && hasError {
+		return errors.New("both code and error are present")
+	}
+	if len(codes) > 1 {
+		return errors.New("multiple codes are present")
+	}
+	if hasCode && codes[0] == "" {
+		return errors.New("code is empty")
+	}
+	return nil
+}
+
+// stateMiddleware accepts callback requests whose state is either the
+// generated state or one of the allowed states, and rejects the others.
+// The allowed state is rewritten to the generated one, hence it passes
+// the state check of the local server handler.
+func stateMiddleware(state string, allowed []string, reject func(error)) func(h http.Handler) http.Handler {
+	allowedStates := map[string]bool{state: true}
+	for _, s := range allowed {
+		allowedStates[s] = true
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isCallback(r) {
+				q := r.URL.Query()
+				got := q.Get("state")
+				if !allowedStates[got] {
+					http.Error(w, "invalid state", http.StatusBadRequest)
+					reject(fmt.Errorf("%w: unexpected state %q", errCallbackRejected, got))
+					return
+				}
+				if got != state {
+					q.Set("state", state)
+					u := *r.URL
+					u.RawQuery = q.Encode()
+					r = r.Clone(r.Context())
+					r.URL = &u
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// pathMiddleware serves requests to path as requests to `/`, which is
+// the path the local server handler expects.
+func pathMiddleware(path string) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == path {
+				u := *r.URL
+				u.Path = "/"
+				u.RawPath = ""
+				r = r.Clone(r.Context())
+				r.URL = &u
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// localServerMiddleware chains all middlewares required by the options,
+// state is the state generated for the authorization request,
+// reject is called with the error when a callback request is rejected.
+// It returns nil if none is required.
+func (s *TokenSource) localServerMiddleware(state string, reject func(error)) func(h http.Handler) http.Handler {
+	var middlewares []func(h http.Handler) http.Handler
+	if s.externalRedirectURI != "" {
+		if u, err := url.Parse(s.externalRedirectURI); err == nil && u.Path != "" && u.Path != "/" {
+			middlewares = append(middlewares, pathMiddleware(u.Path))
+		}
+	}
+	if s.formPost {
+		middlewares = append(middlewares, formPostMiddleware)
+	}
+	if s.requestURI != "" {
+		middlewares = append(middlewares, requestURIMiddleware)
+	}
+	if s.strictCallback {
+		middlewares = append(middlewares, strictCallbackMiddleware(reject))
+	}
+	if s.callbackValidator != nil {
+		middlewares = append(middlewares, callbackValidatorMiddleware(s.callbackValidator, reject))
+	}
+	if len(s.allowedStates) > 0 {
+		middlewares = append(middlewares, stateMiddleware(state, s.allowedStates, reject))
+	}
+	return chainMiddlewares(middlewares...)
+}
+
+// chainMiddlewares chains middlewares in order, the first one handles the
+// request first, nil middlewares are skipped. It returns nil if none is given.
+func chainMiddlewares(middlewares ...func(h http.Handler) http.Handler) func(h http.Handler) http.Handler {
+	var chain []func(h http.Handler) http.Handler
+	for _, m := range middlewares {
+		if m != nil {
+			chain = append(chain, m)
+		}
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	return func(h http.Handler) http.Handler {
+		for i := len(chain) - 1; i >= 0; i-- {
+			h = chain[i](h)
+		}
+		return h
+	}
+}
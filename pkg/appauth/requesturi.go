@@ -0,0 +1,79 @@
+package appauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// UseRequestURI sends the authorization request by reference described in
+// rfc9101, it adds `request_uri` to the authorization URL, and removes `scope`
+// and `response_type` from it since they're in the request object at uri.
+//
+// The authorization server only uses the values in the pre-hosted request
+// object, which can't contain the state, nonce or PKCE challenge generated
+// for every Token call. Hence the state of the request object must be allowed
+// by WithAllowedStates, and the TokenSource must neither use PKCE nor the
+// hybrid flow, otherwise Token returns ErrRequestURIConflict.
+func UseRequestURI(uri string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.requestURI = uri
+	}}
+}
+
+// ErrRequestURIConflict is returned if UseRequestURI is used with options
+// requiring per request values in the authorization request.
+var ErrRequestURIConflict = errors.New("request uri conflicts with per request values")
+
+// validateRequestURI checks the options work with the pre-hosted request object.
+func (s *TokenSource) validateRequestURI() error {
+	if s.requestURI == "" {
+		return nil
+	}
+	if s.usePKCE {
+		return fmt.Errorf("%w: PKCE challenge can't be in the request object, use a TokenSource without PKCE", ErrRequestURIConflict)
+	}
+	if s.hybrid {
+		return fmt.Errorf("%w: nonce of the hybrid flow can't be in the request object", ErrRequestURIConflict)
+	}
+	if len(s.allowedStates) == 0 {
+		return fmt.Errorf("%w: state of the request object must be allowed by WithAllowedStates", ErrRequestURIConflict)
+	}
+	return nil
+}
+
+// requestURIParams are the params removed from the authorization URL
+// when the request object is passed by reference.
+var requestURIParams = []string{"scope", "response_type"}
+
+// requestURIMiddleware removes requestURIParams from the authorization URL
+// which the local server redirects the browser to.
+func requestURIMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/" && !isCallback(r) {
+			w = &authURLRewriter{ResponseWriter: w}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authURLRewriter rewrites the `Location` header of the redirect
+// response before it's written.
+type authURLRewriter struct {
+	http.ResponseWriter
+}
+
+func (w *authURLRewriter) WriteHeader(statusCode int) {
+	if location := w.Header().Get("Location"); location != "" {
+		if u, err := url.Parse(location); err == nil {
+			q := u.Query()
+			for _, param := range requestURIParams {
+				q.Del(param)
+			}
+			u.RawQuery = q.Encode()
+			w.Header().Set("Location", u.String())
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
@@ -0,0 +1,94 @@
+package appauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+// UseExchangeRetry retries the authorization code exchange up to maxAttempts
+// times in total, with delay between attempts, if the token endpoint responds
+// `invalid_grant`. Some providers (e.g. Okta) transiently reject a just issued
+// code before it's propagated in their distributed database.
+func UseExchangeRetry(maxAttempts int, delay time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.exchangeAttempts = maxAttempts
+		s.exchangeDelay = delay
+	}}
+}
+
+// exchangeRetryTransport is a http.RoundTripper retries requests to the
+// token endpoint on `invalid_grant` error.
+type exchangeRetryTransport struct {
+	base        http.RoundTripper
+	tokenURL    *neturl.URL
+	maxAttempts int
+	delay       time.Duration
+}
+
+func (t *exchangeRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !isRequestTo(req, t.tokenURL) || req.GetBody == nil {
+		return base.RoundTrip(req)
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if err != nil || attempt >= t.maxAttempts || !isInvalidGrant(resp) {
+			return resp, err
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.delay):
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+}
+
+// isInvalidGrant tells if resp is an `invalid_grant` error, the response body
+// is consumed and closed if so, otherwise it's restored for the caller.
+func isInvalidGrant(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	errResp := struct {
+		Error string `json:"error"`
+	}{}
+	return json.Unmarshal(body, &errResp) == nil && errResp.Error == "invalid_grant"
+}
+
+// withExchangeRetry returns a copy of client retries the code exchange,
+// the client is returned as is if maxAttempts is less than 2 or tokenURL
+// is invalid.
+func withExchangeRetry(client *http.Client, tokenURL string, maxAttempts int, delay time.Duration) *http.Client {
+	if maxAttempts < 2 {
+		return client
+	}
+	u, err := neturl.Parse(tokenURL)
+	if err != nil {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &exchangeRetryTransport{base: client.Transport, tokenURL: u, maxAttempts: maxAttempts, delay: delay}
+	return &c
+}
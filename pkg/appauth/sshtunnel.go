@@ -0,0 +1,79 @@
+package appauth
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshTunnel is a reverse tunnel listens on remotePort of the SSH server,
+// and forwards connections to the local callback server on localPort.
+type sshTunnel struct {
+	localPort  int
+	remotePort int
+	config     *ssh.ClientConfig
+	remoteAddr string
+}
+
+// start dials the SSH server and starts forwarding connections,
+// it returns the function to stop the tunnel.
+func (t *sshTunnel) start() (func(), error) {
+	client, err := ssh.Dial("tcp", t.remoteAddr, t.config)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to ssh server %s: %w", t.remoteAddr, err)
+	}
+	l, err := client.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", t.remotePort))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("could not listen on ssh server %s: %w", t.remoteAddr, err)
+	}
+	target := fmt.Sprintf("127.0.0.1:%d", t.localPort)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				forward(conn, target)
+			}()
+		}
+	}()
+	return func() {
+		l.Close()
+		client.Close()
+		wg.Wait()
+	}, nil
+}
+
+// forward copies data between the accepted connection and target.
+func forward(conn net.Conn, target string) {
+	defer conn.Close()
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		log.Printf("could not forward connection to %s: %s", target, err)
+		return
+	}
+	defer upstream.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		//nolint:errcheck
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		//nolint:errcheck
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,30 @@
+package appauth
+
+import "errors"
+
+// UseTokenBinding requests tokens bound to the TLS connection described in
+// rfc8471, by adding `token_binding_type=provided` to the authorization
+// request and checking the `tbid` claim of the returned token.
+//
+// crypto/tls doesn't negotiate the token binding TLS extension (rfc8472),
+// hence there is no binding to check the claim against, and Token returns
+// ErrTokenBindingUnsupported when enabled rather than silently returning
+// an unbound token. Use UseMTLSCertificate for certificate-bound tokens
+// described in rfc8705 instead.
+func UseTokenBinding(enabled bool) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.tokenBinding = enabled
+	}}
+}
+
+// ErrTokenBindingUnsupported is returned by Token if UseTokenBinding is
+// enabled, since the TLS connection can't negotiate token binding.
+var ErrTokenBindingUnsupported = errors.New("token binding can't be negotiated on the TLS connection")
+
+// validateTokenBinding fails if token binding is requested.
+func (s *TokenSource) validateTokenBinding() error {
+	if s.tokenBinding {
+		return ErrTokenBindingUnsupported
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package appauth
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+// redactedFields are the secrets sent to or received from the token endpoint,
+// the client credentials and PKCE verifier in the request, and the tokens
+// in the response.
+var redactedFields = []string{"client_secret", "code_verifier", "access_token", "refresh_token", "id_token"}
+
+// redactedPattern matches redactedFields in JSON and form encoded bodies.
+var redactedPattern = func() *regexp.Regexp {
+	quoted := make([]string, 0, len(redactedFields))
+	for _, f := range redactedFields {
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	names := strings.Join(quoted, "|")
+	return regexp.MustCompile(`("(?:` + names + `)"\s*:\s*)"[^"]*"|((?:^|&)(?:` + names + `)=)[^&\s]*`)
+}()
+
+// redactSecrets replaces the values of redactedFields in body.
+func redactSecrets(body []byte) []byte {
+	return redactedPattern.ReplaceAllFunc(body, func(m []byte) []byte {
+		sub := redactedPattern.FindSubmatch(m)
+		if len(sub[1]) > 0 {
+			return append(append([]byte{}, sub[1]...), `"REDACTED"`...)
+		}
+		return append(append([]byte{}, sub[2]...), "REDACTED"...)
+	})
+}
+
+// tokenResponseTransport is a http.RoundTripper tees the request and response
+// bodies of the token endpoint to writer with secrets redacted, it's for
+// debugging only.
+type tokenResponseTransport struct {
+	base     http.RoundTripper
+	tokenURL *neturl.URL
+	writer   io.Writer
+}
+
+func (t *tokenResponseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !isRequestTo(req, t.tokenURL) {
+		return base.RoundTrip(req)
+	}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token request: %w", err)
+		}
+		// RoundTrip must not modify req, send a copy with the body restored
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		fmt.Fprintf(t.writer, "token request: %s\n", redactSecrets(body))
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	fmt.Fprintf(t.writer, "token response %s: %s\n", resp.Status, redactSecrets(body))
+	return resp, nil
+}
+
+// isRequestTo tells if req is sent to the endpoint u.
+func isRequestTo(req *http.Request, u *neturl.URL) bool {
+	return req.URL.Scheme == u.Scheme &&
+		req.URL.Host == u.Host &&
+		req.URL.Path == u.Path
+}
+
+// withTokenResponseLogger returns a copy of client tees the token endpoint
+// responses to w, the client is returned as is if w is nil or tokenURL is invalid.
+func withTokenResponseLogger(client *http.Client, tokenURL string, w io.Writer) *http.Client {
+	if w == nil {
+		return client
+	}
+	u, err := neturl.Parse(tokenURL)
+	if err != nil {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &tokenResponseTransport{base: client.Transport, tokenURL: u, writer: w}
+	return &c
+}
@@ -0,0 +1,302 @@
+// Package ciba implements the poll mode of OpenID Connect Client Initiated
+// Backchannel Authentication (CIBA) flow, the client starts authentication
+// of the end-user identified by a login hint, and polls the token endpoint
+// until the end-user authorizes on the authentication device, e.g. a phone.
+//
+// See https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html
+package ciba
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// GrantType is the grant type used to poll the token endpoint.
+const GrantType = "urn:openid:params:grant-type:ciba"
+
+// authRespSecond is a second of `expires_in` and `interval` in the
+// authentication response, the default interval and the slow_down
+// increment are 5 seconds. It's only shortened by tests.
+var authRespSecond = time.Second
+
+var (
+	errAuthReqExpired = errors.New("authentication request expired")
+	errSlowDown       = errors.New("slow down polling")
+)
+
+// Option configures optional field for TokenSource,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type Option interface {
+	apply(*TokenSource)
+}
+
+type option struct {
+	applyFunc func(*TokenSource)
+}
+
+func (o option) apply(s *TokenSource) {
+	o.applyFunc(s)
+}
+
+// UseHTTPClient sets http client used to make http requests.
+func UseHTTPClient(c *http.Client) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.client = c
+	}}
+}
+
+// Timeout sets additional timeout for the whole flow, including polling.
+func Timeout(t time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.timeout = t
+	}}
+}
+
+// UseClientSecret authenticates the client by HTTP Basic auth with clientSecret,
+// otherwise the client is treated as a public client and only sends `client_id`.
+func UseClientSecret(clientSecret string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.clientSecret = clientSecret
+	}}
+}
+
+// UseBindingMessage sets the `binding_message` shown on both the consumption
+// and authentication devices, to help the end-user confirm the request.
+func UseBindingMessage(message string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.bindingMessage = message
+	}}
+}
+
+// TokenSource implements oauth2.TokenSource interface
+// to provide token via CIBA flow in poll mode.
+type TokenSource struct {
+	backchannelEndpoint string
+	tokenEndpoint       string
+	clientID            string
+	loginHint           string
+	scopes              []string
+
+	clientSecret   string
+	bindingMessage string
+	client         *http.Client
+	timeout        time.Duration
+}
+
+var _ oauth2.TokenSource = &TokenSource{}
+
+type authResponse struct {
+	AuthReqID string `json:"auth_req_id"`
+	ExpiresIn int64  `json:"expires_in"`
+	Interval  int64  `json:"interval"`
+}
+
+type tokenRaw struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+}
+
+type errResponse struct {
+	Code             string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewTokenSource creates a new CIBA token source, the authentication request
+// is sent to backchannelEndpoint for the end-user identified by loginHint,
+// `openid` is added to scopes as required by CIBA.
+// It by default uses `http.DefaultClient` as http client,
+// to change it, set Options when creating the instance.
+func NewTokenSource(backchannelEndpoint string, tokenEndpoint string, clientID string, loginHint string, scopes []string, opts ...Option) *TokenSource {
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	s := &TokenSource{
+		backchannelEndpoint: backchannelEndpoint,
+		tokenEndpoint:       tokenEndpoint,
+		clientID:            clientID,
+		loginHint:           loginHint,
+		scopes:              scopes,
+		client:              http.DefaultClient,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(s)
+		}
+	}
+	return s
+}
+
+// Token starts a new authentication request and polls the token endpoint
+// until the end-user authorizes or denies it, or the request is expired.
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
+		defer cancelFunc()
+	}
+	authResp, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.poll(ctx, authResp)
+}
+
+// authenticate sends the authentication request to the backchannel endpoint.
+func (s *TokenSource) authenticate(ctx context.Context) (*authResponse, error) {
+	params := url.Values{
+		"scope":      {strings.Join(s.scopes, " ")},
+		"login_hint": {s.loginHint},
+	}
+	if s.bindingMessage != "" {
+		params.Set("binding_message", s.bindingMessage)
+	}
+	body, err := s.postForm(ctx, s.backchannelEndpoint, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request backchannel authentication: %w", err)
+	}
+	data := &authResponse{}
+	if err := json.Unmarshal(body, data); err != nil {
+		return nil, err
+	}
+	if data.AuthReqID == "" {
+		return nil, fmt.Errorf("%s is not a valid backchannel authentication response", string(body))
+	}
+	return data, nil
+}
+
+// poll polls the token endpoint with auth_req_id until the token is issued.
+func (s *TokenSource) poll(ctx context.Context, authResp *authResponse) (*oauth2.Token, error) {
+	if authResp.ExpiresIn > 0 {
+		var cancelFn context.CancelCauseFunc
+		ctx, cancelFn = context.WithCancelCause(ctx)
+		defer cancelFn(nil)
+		expireTimer := time.AfterFunc(time.Duration(authResp.ExpiresIn)*authRespSecond, func() {
+			cancelFn(errAuthReqExpired)
+		})
+		defer expireTimer.Stop()
+	}
+	interval := 5 * authRespSecond
+	if authResp.Interval > 0 {
+		interval = time.Duration(authResp.Interval) * authRespSecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stopped polling ciba token: %w", context.Cause(ctx))
+		case <-ticker.C:
+			token, err := s.pollOnce(ctx, authResp.AuthReqID)
+			if errors.Is(err, errSlowDown) {
+				interval += 5 * authRespSecond
+				ticker.Reset(interval)
+				continue
+			}
+			if err != nil || token != nil {
+				return token, err
+			}
+		}
+	}
+}
+
+// pollOnce requests token endpoint once, it returns nil token and nil error
+// when the authorization is still pending.
+func (s *TokenSource) pollOnce(ctx context.Context, authReqID string) (*oauth2.Token, error) {
+	params := url.Values{
+		"grant_type":  {GrantType},
+		"auth_req_id": {authReqID},
+	}
+	body, err := s.postForm(ctx, s.tokenEndpoint, params)
+	if err != nil {
+		var errResp *errResponse
+		if !errors.As(err, &errResp) {
+			return nil, err
+		}
+		switch errResp.Code {
+		case "authorization_pending":
+			return nil, nil
+		case "slow_down":
+			return nil, errSlowDown
+		}
+		return nil, err
+	}
+	data := &tokenRaw{}
+	if err := json.Unmarshal(body, data); err != nil {
+		return nil, err
+	}
+	if data.AccessToken == "" {
+		return nil, fmt.Errorf("%s is not a valid token response", string(body))
+	}
+	token := &oauth2.Token{
+		AccessToken:  data.AccessToken,
+		RefreshToken: data.RefreshToken,
+		TokenType:    data.TokenType,
+	}
+	if data.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+	}
+	if data.IDToken != "" {
+		token = token.WithExtra(map[string]interface{}{
+			"id_token": data.IDToken,
+		})
+	}
+	return token, nil
+}
+
+func (e *errResponse) Error() string {
+	if e.ErrorDescription == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s, %s", e.Code, e.ErrorDescription)
+}
+
+// postForm posts params to endpoint with client authentication, and returns
+// the response body, or *errResponse if the endpoint responds an error.
+func (s *TokenSource) postForm(ctx context.Context, endpoint string, params url.Values) ([]byte, error) {
+	if s.clientSecret == "" {
+		params.Set("client_id", s.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if s.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return nil, cause
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		errResp := &errResponse{}
+		if err := json.Unmarshal(body, errResp); err == nil && errResp.Code != "" {
+			return nil, errResp
+		}
+		return nil, fmt.Errorf("response code %d, %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
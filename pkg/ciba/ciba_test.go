@@ -0,0 +1,121 @@
+package ciba
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiewei/otoken/pkg/internal/oauthtest"
+)
+
+// shortenSeconds makes a second of the authentication response 10ms.
+func shortenSeconds(t *testing.T) {
+	authRespSecond = 10 * time.Millisecond
+	t.Cleanup(func() {
+		authRespSecond = time.Second
+	})
+}
+
+// newProvider starts a provider authenticating alice, its token endpoint
+// responds the polls in order, it returns the backchannel and token URLs,
+// and the time of every poll.
+func newProvider(t *testing.T, expiresIn int, polls ...oauthtest.Response) (string, string, func() []time.Time) {
+	var mu sync.Mutex
+	var polledAt []time.Time
+	srv := oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		if id, secret, basic := oauthtest.ClientCredentials(r); !basic || id != "client" || secret != "secret" {
+			t.Errorf("basic auth = %q:%q, want client:secret", id, secret)
+		}
+		if r.URL.Path == "/bc" {
+			if r.PostForm.Get("login_hint") != "alice" || r.PostForm.Get("scope") != "openid profile" {
+				t.Errorf("authentication request = %v, want login_hint alice and scope openid profile", r.PostForm)
+			}
+			return oauthtest.JSON(http.StatusOK, map[string]interface{}{"auth_req_id": "req-1", "expires_in": expiresIn, "interval": 1})
+		}
+		if r.PostForm.Get("grant_type") != GrantType || r.PostForm.Get("auth_req_id") != "req-1" {
+			t.Errorf("poll request = %v, want ciba grant of req-1", r.PostForm)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		polledAt = append(polledAt, time.Now())
+		if len(polledAt) > len(polls) {
+			return polls[len(polls)-1]
+		}
+		return polls[len(polledAt)-1]
+	})
+	return srv.URL + "/bc", srv.URL + "/token", func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time{}, polledAt...)
+	}
+}
+
+func pollError(code string) oauthtest.Response {
+	return oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func TestTokenPendingAndSlowDown(t *testing.T) {
+	shortenSeconds(t)
+	bcURL, tokenURL, polledAt := newProvider(t, 60,
+		pollError("authorization_pending"),
+		pollError("slow_down"),
+		oauthtest.JSON(http.StatusOK, map[string]interface{}{"access_token": "at", "token_type": "Bearer", "expires_in": 60, "id_token": "id"}),
+	)
+	token, err := NewTokenSource(bcURL, tokenURL, "client", "alice", []string{"profile"}, UseClientSecret("secret")).Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "at" || token.Extra("id_token") != "id" {
+		t.Errorf("Token() = %+v, want access token at with id token", token)
+	}
+	polls := polledAt()
+	if len(polls) != 3 {
+		t.Fatalf("polled %d times, want 3", len(polls))
+	}
+	// the interval is increased by 5 seconds after slow_down
+	if gap := polls[2].Sub(polls[1]); gap < 6*authRespSecond {
+		t.Errorf("polled again %s after slow_down, want at least %s", gap, 6*authRespSecond)
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	shortenSeconds(t)
+	tests := []struct {
+		name      string
+		expiresIn int
+		poll      oauthtest.Response
+		wantErr   error
+		wantMsg   string
+	}{
+		{
+			name:      "access denied",
+			expiresIn: 60,
+			poll:      oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": "access_denied", "error_description": "user declined"}),
+			wantMsg:   "access_denied, user declined",
+		},
+		{
+			name:      "expired",
+			expiresIn: 3,
+			poll:      pollError("authorization_pending"),
+			wantErr:   errAuthReqExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bcURL, tokenURL, _ := newProvider(t, tt.expiresIn, tt.poll)
+			_, err := NewTokenSource(bcURL, tokenURL, "client", "alice", []string{"profile"}, UseClientSecret("secret")).Token()
+			if err == nil {
+				t.Fatal("Token() returned no error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Token() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Token() error = %v, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
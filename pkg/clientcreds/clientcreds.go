@@ -0,0 +1,103 @@
+// Package clientcreds implements the OAuth2 client credentials
+// grant described in rfc6749 section 4.4. It's used by a client
+// to get access token on behalf of itself, e.g. for service to
+// service authentication.
+package clientcreds
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+// GrantType is the grant type used by client credentials requests.
+const GrantType = "client_credentials"
+
+// Option configures optional field for TokenSource,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type Option interface {
+	apply(*TokenSource)
+}
+
+type option struct {
+	applyFunc func(*TokenSource)
+}
+
+func (o option) apply(s *TokenSource) {
+	o.applyFunc(s)
+}
+
+// UseHTTPClient sets http client used to make http requests.
+func UseHTTPClient(c *http.Client) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.client = c
+	}}
+}
+
+// Timeout sets additional timeout for the token request.
+func Timeout(t time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.timeout = t
+	}}
+}
+
+// TokenSource implements oauth2.TokenSource interface
+// to provide token via client credentials grant described
+// in rfc6749 section 4.4.
+type TokenSource struct {
+	tokenEndpoint string
+	clientID      string
+	clientSecret  string
+	scopes        []string
+
+	client  *http.Client
+	timeout time.Duration
+}
+
+var _ oauth2.TokenSource = &TokenSource{}
+
+// NewTokenSource creates a new client credentials token source.
+// It by default uses `http.DefaultClient` as http client,
+// to change it, set Options when creating the instance.
+func NewTokenSource(tokenEndpoint string, clientID string, clientSecret string, scopes []string, opts ...Option) *TokenSource {
+	s := &TokenSource{
+		tokenEndpoint: tokenEndpoint,
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		scopes:        scopes,
+
+		client: http.DefaultClient,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(s)
+		}
+	}
+	return s
+}
+
+// Token requests a new oauth2.Token by the client credentials,
+// the client is authenticated by HTTP Basic auth.
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, s.client)
+	if s.timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
+		defer cancelFunc()
+	}
+	cfg := clientcredentials.Config{
+		ClientID:     s.clientID,
+		ClientSecret: s.clientSecret,
+		TokenURL:     s.tokenEndpoint,
+		Scopes:       s.scopes,
+		AuthStyle:    oauth2.AuthStyleInHeader,
+	}
+	token, err := cfg.Token(ctx)
+	return token, types.TokenError(err)
+}
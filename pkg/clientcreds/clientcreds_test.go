@@ -0,0 +1,80 @@
+package clientcreds
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/tiewei/otoken/pkg/internal/oauthtest"
+)
+
+// tokenEndpoint responds resp to the client credentials request of
+// client:secret with scopes `read write`.
+func tokenEndpoint(t *testing.T, resp oauthtest.Response) string {
+	return oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		if got := r.PostForm.Get("grant_type"); got != GrantType {
+			t.Errorf("grant_type = %q, want %q", got, GrantType)
+		}
+		if got := r.PostForm.Get("scope"); got != "read write" {
+			t.Errorf("scope = %q, want %q", got, "read write")
+		}
+		if id, secret, basic := oauthtest.ClientCredentials(r); !basic || id != "client" || secret != "secret" {
+			t.Errorf("basic auth = %q:%q, want client:secret", id, secret)
+		}
+		return resp
+	}).URL
+}
+
+func TestToken(t *testing.T) {
+	tokenURL := tokenEndpoint(t, oauthtest.JSON(http.StatusOK, map[string]interface{}{
+		"access_token": "at", "token_type": "Bearer", "expires_in": 3600, "scope": "read write",
+	}))
+	token, err := NewTokenSource(tokenURL, "client", "secret", []string{"read", "write"}).Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "at" || token.TokenType != "Bearer" {
+		t.Errorf("Token() = %+v, want access token at of type Bearer", token)
+	}
+	if until := time.Until(token.Expiry); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("token expires in %s, want 1h", until)
+	}
+	if scope := token.Extra("scope"); scope != "read write" {
+		t.Errorf("scope = %v, want %q", scope, "read write")
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    oauthtest.Response
+		wantErr string
+	}{
+		{
+			name:    "json error",
+			resp:    oauthtest.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "bad secret"}),
+			wantErr: "failed to get token: invalid_client, bad secret",
+		},
+		{
+			name:    "non-json error",
+			resp:    oauthtest.Text(http.StatusUnauthorized, "upstream unavailable"),
+			wantErr: "failed to get token: response code 401, upstream unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTokenSource(tokenEndpoint(t, tt.resp), "client", "secret", []string{"read", "write"}).Token()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Token() error = %v, want %q", err, tt.wantErr)
+			}
+			var retrieveErr *oauth2.RetrieveError
+			if !errors.As(err, &retrieveErr) || retrieveErr.Response.StatusCode != http.StatusUnauthorized {
+				t.Errorf("Token() error = %v, want *oauth2.RetrieveError with status 401", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package devauth
+
+import "time"
+
+// Clock provides the current time, tickers and timers, which can be replaced
+// by a fake clock in tests of the time-dependent polling logic.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc calls f in its own goroutine after d, see time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks on C at intervals, see time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// Timer is the timer created by Clock.AfterFunc, see time.Timer.
+type Timer interface {
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// WithClock sets the clock used by Authorizor, it defaults to the real time.
+func WithClock(c Clock) AuthorizorOption {
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.clock = c
+	}}
+}
@@ -21,7 +21,9 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tiewei/otoken/pkg/openid"
@@ -61,6 +63,9 @@ type UserCodeURI struct {
 
 	// A verification URI that includes the "user_code".
 	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+
+	// When the "device_code" and "user_code" expire.
+	ExpiresAt time.Time `json:"-"`
 }
 
 // deviceCodeResponse holds information about the device auth flow
@@ -92,31 +97,208 @@ type tokenErrResponse struct {
 	ErrorDescription string `json:"error_description"`
 }
 
-// Authorizor implements device authorization flow
+// Authorizor implements device authorization flow.
+//
+// PollToken and the other read methods are safe for concurrent use,
+// RequestCode can only be called once per Authorizor, and must complete
+// before polling.
 type Authorizor struct {
+	authorizorConfig
+
+	mu           sync.RWMutex
+	requestOnce  sync.Once
+	authResp     *deviceCodeResponse
+	codeIssuedAt time.Time
+}
+
+// authorizorConfig is the configuration of Authorizor, it's copied as a whole
+// by clone, hence every AuthorizorOption must only set fields of it.
+type authorizorConfig struct {
 	tokenEndpoint string
 	authEndpoint  string
 	clientID      string
 	scopes        []string
-	authResp      *deviceCodeResponse
+
+	clientAssertion     func() (string, error)
+	clientAssertionType string
+	fieldAliases        map[string]string
+	parTokenPoll        bool
+	parEndpoint         string
+	redirectURI         string
+	clock               Clock
+	pollingJitter       float64
+	deviceCodeField     string
+}
+
+// AuthorizorOption configures optional field for Authorizor,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type AuthorizorOption interface {
+	applyAuthorizor(*Authorizor)
+}
+
+type authorizorOption struct {
+	applyFunc func(*Authorizor)
+}
+
+func (o authorizorOption) applyAuthorizor(d *Authorizor) {
+	o.applyFunc(d)
+}
+
+// WithClientAssertion authenticates the client by `client_assertion_type` and
+// `client_assertion` params instead of `client_id`, the assertion function is
+// called for every request to get a fresh assertion.
+func WithClientAssertion(assertion func() (string, error), assertionType string) AuthorizorOption {
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.clientAssertion = assertion
+		d.clientAssertionType = assertionType
+	}}
+}
+
+// verificationURLAliases returns the aliases of the `verification_url`
+// spelling from the rfc8628 drafts, which providers still return in
+// place of `verification_uri`.
+func verificationURLAliases() map[string]string {
+	return map[string]string{
+		"verification_url":          "verification_uri",
+		"verification_url_complete": "verification_uri_complete",
+	}
+}
+
+// OktaAliases returns the aliases for older Okta configurations, which
+// return the `verification_url` spelling, the map is a new copy every call.
+func OktaAliases() map[string]string {
+	return verificationURLAliases()
+}
+
+// Auth0Aliases returns the aliases for Auth0 legacy tenants, which return
+// the same `verification_url` spelling as Okta, the map is a new copy every
+// call.
+func Auth0Aliases() map[string]string {
+	return verificationURLAliases()
+}
+
+// WithFieldAliases renames fields in the device code response before
+// unmarshalling it, fieldMap maps the provider field name to the
+// rfc8628 field name, e.g. `verification_url` to `verification_uri`.
+// A field is not renamed if the response already contains the rfc8628 one,
+// aliases are applied in the sorted order of the provider field names.
+func WithFieldAliases(fieldMap map[string]string) AuthorizorOption {
+	aliases := make(map[string]string, len(fieldMap))
+	for from, to := range fieldMap {
+		aliases[from] = to
+	}
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.fieldAliases = aliases
+	}}
+}
+
+// WithRedirectURI sends `redirect_uri` in the device authorization request,
+// it's not part of rfc8628 but required by some providers, e.g. when the
+// client is registered behind a reverse proxy.
+func WithRedirectURI(uri string) AuthorizorOption {
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.redirectURI = uri
+	}}
+}
+
+// WithDeviceCodeFieldName changes the name of the `device_code` parameter
+// posted to the token endpoint while polling, for providers not compliant
+// with rfc8628. The device code response is parsed as is, use
+// WithFieldAliases to rename its fields.
+func WithDeviceCodeFieldName(fieldName string) AuthorizorOption {
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.deviceCodeField = fieldName
+	}}
 }
 
 // New creates a new Authorizor instance from Endpoint, clientID and scopes
-func New(tokenEndpoint string, authEndpoint string, clientID string, scopes []string) *Authorizor {
-	return &Authorizor{
-		tokenEndpoint: tokenEndpoint,
-		authEndpoint:  authEndpoint,
-		clientID:      clientID,
-		scopes:        openid.EnsureOpenIDScope(scopes),
+func New(tokenEndpoint string, authEndpoint string, clientID string, scopes []string, opts ...AuthorizorOption) *Authorizor {
+	d := &Authorizor{
+		authorizorConfig: authorizorConfig{
+			tokenEndpoint: tokenEndpoint,
+			authEndpoint:  authEndpoint,
+			clientID:      clientID,
+			scopes:        openid.EnsureOpenIDScope(scopes),
+			clock:         realClock{},
+		},
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.applyAuthorizor(d)
+		}
 	}
+	return d
+}
+
+// clone returns a new Authorizor with the same configuration as d,
+// but no device code requested.
+func (d *Authorizor) clone() *Authorizor {
+	return &Authorizor{authorizorConfig: d.authorizorConfig}
+}
+
+// deviceCode returns the device code response and when it's issued,
+// the response is nil if no code is requested.
+func (d *Authorizor) deviceCode() (*deviceCodeResponse, time.Time) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.authResp, d.codeIssuedAt
+}
+
+// clientParams returns the params to identify the client in requests.
+func (d *Authorizor) clientParams() (url.Values, error) {
+	if d.clientAssertion == nil {
+		return url.Values{"client_id": {d.clientID}}, nil
+	}
+	assertion, err := d.clientAssertion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client assertion: %w", err)
+	}
+	return url.Values{
+		"client_assertion_type": {d.clientAssertionType},
+		"client_assertion":      {assertion},
+	}, nil
 }
 
-// RequestCode requests device authorization endpoint to authorization codes
+// RequestCode requests device authorization endpoint to authorization codes,
+// it's the same as RequestCodeWithContext. It can only be called once, a failed
+// request, e.g. by a network error, can't be retried on the same Authorizor,
+// create a new Authorizor to retry.
 func (d *Authorizor) RequestCode(ctx context.Context, client *http.Client) (*UserCodeURI, error) {
-	resp, err := client.PostForm(d.authEndpoint, url.Values{
-		"client_id": {d.clientID},
-		"scope":     {strings.Join(d.scopes, " ")},
+	return d.RequestCodeWithContext(ctx, client)
+}
+
+var errCodeRequested = errors.New("device code already requested, create a new Authorizor to request again")
+
+// RequestCodeWithContext requests device authorization endpoint to authorization codes,
+// the request is aborted as soon as ctx is done. It's not safe for concurrent use, and
+// returns error if called more than once, even if the first call failed, hence a failed
+// request can't be retried on the same Authorizor, create a new Authorizor to retry.
+func (d *Authorizor) RequestCodeWithContext(ctx context.Context, client *http.Client) (*UserCodeURI, error) {
+	var userURI *UserCodeURI
+	err := errCodeRequested
+	d.requestOnce.Do(func() {
+		userURI, err = d.requestCode(ctx, client)
 	})
+	return userURI, err
+}
+
+// requestCode requests the device code and saves the response.
+func (d *Authorizor) requestCode(ctx context.Context, client *http.Client) (*UserCodeURI, error) {
+	params, err := d.clientParams()
+	if err != nil {
+		return nil, err
+	}
+	params.Set("scope", strings.Join(d.scopes, " "))
+	if d.redirectURI != "" {
+		params.Set("redirect_uri", d.redirectURI)
+	}
+	if d.parTokenPoll {
+		if params, err = d.pushAuthorizationRequest(ctx, client, params); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := postForm(ctx, client, d.authEndpoint, params)
 	if err != nil {
 		return nil, err
 	}
@@ -126,63 +308,211 @@ func (d *Authorizor) RequestCode(ctx context.Context, client *http.Client) (*Use
 		return nil, fmt.Errorf("failed to request device code: response code %d, %s", resp.StatusCode, string(body))
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.fieldAliases) > 0 {
+		if body, err = renameFields(body, d.fieldAliases); err != nil {
+			return nil, err
+		}
+	}
 	data := &deviceCodeResponse{}
-	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+	if err := json.Unmarshal(body, data); err != nil {
 		return nil, err
 	}
 	if data.DeviceCode == "" || data.UserCode == "" || data.VerificationURI == "" || data.ExpiresIn <= 0 {
 		return nil, fmt.Errorf("%#v is not a valid device code response", data)
 	}
-	d.authResp = data
-	if d.authResp.Interval == 0 {
-		d.authResp.Interval = 5
+	return d.setCode(data), nil
+}
+
+// setCode saves the device code response, and returns its UserCodeURI.
+func (d *Authorizor) setCode(data *deviceCodeResponse) *UserCodeURI {
+	if data.Interval == 0 {
+		data.Interval = 5
 	}
+	issuedAt := d.clock.Now()
+	d.mu.Lock()
+	d.authResp = data
+	d.codeIssuedAt = issuedAt
+	d.mu.Unlock()
 	return &UserCodeURI{
-		UserCode:                d.authResp.UserCode,
-		VerificationURI:         d.authResp.VerificationURI,
-		VerificationURIComplete: d.authResp.VerificationURIComplete,
-	}, nil
+		UserCode:                data.UserCode,
+		VerificationURI:         data.VerificationURI,
+		VerificationURIComplete: data.VerificationURIComplete,
+		ExpiresAt:               issuedAt.Add(time.Duration(data.ExpiresIn) * time.Second),
+	}
+}
+
+// RemainingDeviceCodeTTL returns how long the device code requested by
+// RequestCode is still valid, it returns 0 if no code is requested or
+// the code is expired.
+func (d *Authorizor) RemainingDeviceCodeTTL() time.Duration {
+	authResp, issuedAt := d.deviceCode()
+	if authResp == nil {
+		return 0
+	}
+	remaining := time.Duration(authResp.ExpiresIn)*time.Second - d.clock.Now().Sub(issuedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// IsExpired reports whether the device code requested by RequestCode
+// is expired, it returns false if no code is requested.
+func (d *Authorizor) IsExpired() bool {
+	authResp, issuedAt := d.deviceCode()
+	return authResp != nil && d.clock.Now().Sub(issuedAt) > time.Duration(authResp.ExpiresIn)*time.Second
 }
 
 const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
 
-// PollToken polls the server from token endpoint until an access token is granted or denied.
+var (
+	errDeviceCodeExpired = errors.New("device code expired")
+	errSlowDown          = errors.New("slow down polling")
+)
+
+// PollToken polls the server from token endpoint until an access token is granted or denied,
+// it's the same as PollTokenWithContext.
 func (d *Authorizor) PollToken(ctx context.Context, client *http.Client) (*oauth2.Token, error) {
-	ctx, cancelFn := context.WithTimeout(ctx, time.Duration(d.authResp.ExpiresIn)*time.Second)
-	defer cancelFn()
-	ticker := time.NewTicker(time.Duration(d.authResp.Interval) * time.Second)
+	return d.PollTokenWithContext(ctx, client)
+}
+
+// PollTokenWithContext polls the server from token endpoint until an access token is granted or denied.
+// Polling stops as soon as ctx is done or the device code is expired, and the returned
+// error contains the cause of the cancellation.
+func (d *Authorizor) PollTokenWithContext(ctx context.Context, client *http.Client) (*oauth2.Token, error) {
+	return d.poll(ctx, client, nil)
+}
+
+// poll polls the token, and calls onAttempt after every attempt if it's not nil.
+func (d *Authorizor) poll(ctx context.Context, client *http.Client, onAttempt func(PollAttempt)) (*oauth2.Token, error) {
+	authResp, _ := d.deviceCode()
+	if authResp == nil {
+		return nil, errors.New("no device code requested, call RequestCode first")
+	}
+	ctx, cancelFn := context.WithCancelCause(ctx)
+	defer cancelFn(nil)
+	expireTimer := d.clock.AfterFunc(d.RemainingDeviceCodeTTL(), func() {
+		cancelFn(errDeviceCodeExpired)
+	})
+	defer expireTimer.Stop()
+	interval := time.Duration(authResp.Interval) * time.Second
+	ticker := d.clock.NewTicker(d.jittered(interval))
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, errors.New("timeout polling device token")
-		case <-ticker.C:
-			resp, err := client.PostForm(d.tokenEndpoint, url.Values{
-				"client_id":   {d.clientID},
-				"device_code": {d.authResp.DeviceCode},
-				"grant_type":  {deviceGrantType},
-			})
-			if err != nil {
-				return nil, err
+			return nil, fmt.Errorf("stopped polling device token: %w", context.Cause(ctx))
+		case <-ticker.C():
+			if d.RemainingDeviceCodeTTL() <= 0 {
+				return nil, fmt.Errorf("stopped polling device token: %w", errDeviceCodeExpired)
+			}
+			token, body, err := d.pollOnce(ctx, client, authResp.DeviceCode)
+			if onAttempt != nil {
+				onAttempt(PollAttempt{
+					Time:     d.clock.Now(),
+					Response: string(body),
+					Error:    err,
+				})
+			}
+			if errors.Is(err, errSlowDown) {
+				// https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+				interval += 5 * time.Second
+				ticker.Reset(d.jittered(interval))
+				continue
+			}
+			if err != nil || token != nil {
+				return token, err
 			}
-			defer resp.Body.Close()
-			data := struct {
-				tokenRaw
-				tokenErrResponse
-			}{}
-
-			if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-				return nil, err
-			} else if data.tokenRaw.AccessToken != "" {
-				return &oauth2.Token{
-					AccessToken:  data.tokenRaw.AccessToken,
-					RefreshToken: data.tokenRaw.RefreshToken,
-					TokenType:    data.tokenRaw.TokenType,
-					Expiry:       time.Now().Add(time.Duration(data.tokenRaw.ExpiresIn) * time.Second),
-				}, nil
-			} else if data.tokenErrResponse.Error != "authorization_pending" {
-				return nil, errors.New(data.tokenErrResponse.ErrorDescription)
+			if d.pollingJitter > 0 {
+				ticker.Reset(d.jittered(interval))
 			}
 		}
 	}
 }
+
+// pollOnce requests token endpoint once, it returns nil token and nil error
+// when the authorization is still pending, along with the response body.
+func (d *Authorizor) pollOnce(ctx context.Context, client *http.Client, deviceCode string) (*oauth2.Token, []byte, error) {
+	params, err := d.clientParams()
+	if err != nil {
+		return nil, nil, err
+	}
+	deviceCodeField := "device_code"
+	if d.deviceCodeField != "" {
+		deviceCodeField = d.deviceCodeField
+	}
+	params.Set(deviceCodeField, deviceCode)
+	params.Set("grant_type", deviceGrantType)
+	resp, err := postForm(ctx, client, d.tokenEndpoint, params)
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return nil, nil, fmt.Errorf("stopped polling device token: %w", cause)
+		}
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := struct {
+		tokenRaw
+		tokenErrResponse
+	}{}
+
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, body, err
+	} else if data.tokenRaw.AccessToken != "" {
+		return &oauth2.Token{
+			AccessToken:  data.tokenRaw.AccessToken,
+			RefreshToken: data.tokenRaw.RefreshToken,
+			TokenType:    data.tokenRaw.TokenType,
+			Expiry:       d.clock.Now().Add(time.Duration(data.tokenRaw.ExpiresIn) * time.Second),
+		}, body, nil
+	} else if data.tokenErrResponse.Error == "slow_down" {
+		return nil, body, errSlowDown
+	} else if data.tokenErrResponse.Error != "authorization_pending" {
+		return nil, body, errors.New(data.tokenErrResponse.ErrorDescription)
+	}
+	return nil, body, nil
+}
+
+// renameFields renames the top level fields of JSON object according to aliases,
+// in the sorted order of the source names so the result is deterministic.
+func renameFields(body []byte, aliases map[string]string) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(aliases))
+	for from := range aliases {
+		names = append(names, from)
+	}
+	sort.Strings(names)
+	for _, from := range names {
+		to := aliases[from]
+		value, ok := fields[from]
+		if !ok {
+			continue
+		}
+		if _, exists := fields[to]; !exists {
+			fields[to] = value
+		}
+		delete(fields, from)
+	}
+	return json.Marshal(fields)
+}
+
+// postForm is like http.Client.PostForm but aborts the request when ctx is done.
+func postForm(ctx context.Context, client *http.Client, endpoint string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return client.Do(req)
+}
@@ -0,0 +1,239 @@
+package devauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiewei/otoken/pkg/devauth"
+	"github.com/tiewei/otoken/pkg/devauth/devauthtest"
+	"golang.org/x/oauth2"
+)
+
+// fakeClock is a Clock only advanced by Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	timers  []*fakeTimer
+	resets  chan time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0), resets: make(chan time.Duration, 10)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) devauth.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) devauth.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, and fires the due tickers and timers.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if !t.stopped && !t.next.After(c.now) {
+			select {
+			case t.c <- c.now:
+			default:
+			}
+			t.next = c.now.Add(t.period)
+		}
+	}
+	for _, t := range c.timers {
+		if !t.stopped && !t.at.After(c.now) {
+			t.stopped = true
+			go t.f()
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	t.period = d
+	t.next = t.clock.now.Add(d)
+	t.clock.mu.Unlock()
+	t.clock.resets <- d
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
+
+func TestPollSlowDown(t *testing.T) {
+	authURL, tokenURL, cleanup := devauthtest.GenerateTestScenario([]devauthtest.Step{
+		devauthtest.ReturnDeviceCode("device-code", devauth.UserCodeURI{UserCode: "CODE"}, 600, 1),
+		devauthtest.ReturnPollResponse("slow_down"),
+		devauthtest.ReturnToken("at", "rt", "Bearer", 60),
+	})
+	defer cleanup()
+
+	clock := newFakeClock()
+	auth := devauth.New(tokenURL, authURL, "test-client", nil, devauth.WithClock(clock))
+	if _, err := auth.RequestCode(context.Background(), http.DefaultClient); err != nil {
+		t.Fatalf("RequestCode() returned error: %s", err)
+	}
+	type result struct {
+		token   *oauth2.Token
+		history *devauth.PollHistory
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		token, history, err := auth.PollWithHistory(context.Background(), http.DefaultClient)
+		done <- result{token, history, err}
+	}()
+
+	// the ticker is created by the polling goroutine, advance until it ticks
+	var reset time.Duration
+	for reset == 0 {
+		clock.Advance(time.Second)
+		select {
+		case reset = <-clock.resets:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	// rfc8628 section 3.5, the interval is increased by 5 seconds after slow_down
+	if reset != 6*time.Second {
+		t.Errorf("ticker reset to %s after slow_down, want 6s", reset)
+	}
+	clock.Advance(5 * time.Second)
+	clock.Advance(time.Second)
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("PollWithHistory() returned error: %s", res.err)
+	}
+	if res.token.AccessToken != "at" {
+		t.Errorf("PollWithHistory() = %+v, want access token at", res.token)
+	}
+	if len(res.history.Attempts) != 2 {
+		t.Fatalf("got %d attempts, want 2", len(res.history.Attempts))
+	}
+	if gap := res.history.Attempts[1].Time.Sub(res.history.Attempts[0].Time); gap != 6*time.Second {
+		t.Errorf("polled again %s after slow_down, want 6s", gap)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	payloads := make(chan map[string]interface{}, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("webhook got invalid payload: %s", err)
+		}
+		payload["authorization"] = r.Header.Get("Authorization")
+		payloads <- payload
+	}))
+	defer webhook.Close()
+
+	src := devauthtest.NewTestTokenSource(t, devauthtest.Scenario{AccessToken: "at", TokenType: "Bearer", ExpiresIn: 60},
+		devauth.UseWebhookNotifier(webhook.URL, map[string]string{"Authorization": "Bearer hook"}))
+	if _, err := src.Token(); err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	payload := <-payloads
+	if payload["user_code"] != "TEST-CODE" || payload["authorization"] != "Bearer hook" {
+		t.Errorf("webhook got %v, want user_code TEST-CODE and authorization header", payload)
+	}
+	expiresAt, _ := payload["expires_at"].(string)
+	if _, err := time.Parse(time.RFC3339, expiresAt); err != nil {
+		t.Errorf("webhook got expires_at %q, want RFC3339 time: %s", expiresAt, err)
+	}
+}
+
+func TestPARTokenPollPushesAuthorizationRequest(t *testing.T) {
+	var pushed, requested, polled url.Values
+	mux := http.NewServeMux()
+	mux.HandleFunc("/par", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		pushed = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"request_uri": "urn:par:1", "expires_in": 60})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requested = r.PostForm
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code": "dc", "user_code": "UC", "verification_uri": "https://example.com", "expires_in": 600, "interval": 1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		polled = r.PostForm
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at", "token_type": "Bearer", "expires_in": 60})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	auth := devauth.New(server.URL+"/token", server.URL+"/device", "test-client", []string{"profile"},
+		devauth.WithPAREndpoint(server.URL+"/par"), devauth.WithPARTokenPoll(true))
+	if _, err := auth.RequestCode(context.Background(), http.DefaultClient); err != nil {
+		t.Fatalf("RequestCode() returned error: %s", err)
+	}
+	if _, err := auth.PollToken(context.Background(), http.DefaultClient); err != nil {
+		t.Fatalf("PollToken() returned error: %s", err)
+	}
+	if pushed.Get("scope") != "profile openid" || pushed.Get("client_id") != "test-client" {
+		t.Errorf("pushed %v, want the device authorization request", pushed)
+	}
+	if requested.Get("request_uri") != "urn:par:1" || requested.Get("scope") != "" {
+		t.Errorf("requested device code with %v, want only the pushed request_uri", requested)
+	}
+	if polled.Get("device_code") != "dc" || polled.Get("request_uri") != "" {
+		t.Errorf("polled token with %v, want device_code and no request_uri", polled)
+	}
+}
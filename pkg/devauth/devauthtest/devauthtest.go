@@ -0,0 +1,158 @@
+// Package devauthtest provides a local server simulating the device
+// authorization flow, for tests of code using the devauth package.
+package devauthtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/tiewei/otoken/pkg/devauth"
+)
+
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	testDeviceCode = "test-device-code"
+	testUserCode   = "TEST-CODE"
+)
+
+// Scenario configures the device authorization flow simulated
+// by NewTestTokenSource.
+type Scenario struct {
+	// PendingResponses is the number of `authorization_pending`
+	// responses returned before the token is granted.
+	PendingResponses int
+	// SlowDown returns a `slow_down` response on the first poll.
+	SlowDown bool
+
+	// The token values returned once granted.
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	ExpiresIn    int
+}
+
+// Steps returns the steps of the scenario for GenerateTestScenario.
+func (s Scenario) Steps() []Step {
+	steps := []Step{
+		ReturnDeviceCode(testDeviceCode, devauth.UserCodeURI{UserCode: testUserCode}, 600, 1),
+	}
+	if s.SlowDown {
+		steps = append(steps, ReturnPollResponse("slow_down"))
+	}
+	for i := 0; i < s.PendingResponses; i++ {
+		steps = append(steps, ReturnPollResponse("authorization_pending"))
+	}
+	return append(steps, ReturnToken(s.AccessToken, s.RefreshToken, s.TokenType, s.ExpiresIn))
+}
+
+// NewTestTokenSource creates a devauth.TokenSource against a local httptest.Server
+// simulating scenario, the server is closed when the test finishes.
+// The TokenSource doesn't open browser or wait for user confirmation.
+func NewTestTokenSource(t testing.TB, scenario Scenario, opts ...devauth.Option) *devauth.TokenSource {
+	t.Helper()
+	authURL, tokenURL, cleanup := GenerateTestScenario(scenario.Steps())
+	t.Cleanup(cleanup)
+
+	opts = append([]devauth.Option{
+		devauth.UsePrompter(func(string, bool) {}),
+		devauth.UseURLOpener(func(string) {}),
+	}, opts...)
+	return devauth.NewTokenSource(authURL, tokenURL, "test-client", nil, opts...)
+}
+
+// Step is a response returned by the server of GenerateTestScenario,
+// create it by ReturnDeviceCode, ReturnPollResponse or ReturnToken.
+type Step struct {
+	path   string
+	status int
+	body   map[string]interface{}
+}
+
+// ReturnDeviceCode responds the device authorization request with
+// deviceCode and userURI, the verification URI of the server is used
+// if userURI.VerificationURI is empty.
+func ReturnDeviceCode(deviceCode string, userURI devauth.UserCodeURI, expiresIn int, interval int) Step {
+	return Step{path: "/device", status: http.StatusOK, body: map[string]interface{}{
+		"device_code":               deviceCode,
+		"user_code":                 userURI.UserCode,
+		"verification_uri":          userURI.VerificationURI,
+		"verification_uri_complete": userURI.VerificationURIComplete,
+		"expires_in":                expiresIn,
+		"interval":                  interval,
+	}}
+}
+
+// ReturnPollResponse responds the token request with the error code,
+// e.g. `authorization_pending`, `slow_down` or `access_denied`.
+func ReturnPollResponse(errCode string) Step {
+	return Step{path: "/token", status: http.StatusBadRequest, body: map[string]interface{}{"error": errCode}}
+}
+
+// ReturnToken responds the token request with the token.
+func ReturnToken(accessToken string, refreshToken string, tokenType string, expiresIn int) Step {
+	return Step{path: "/token", status: http.StatusOK, body: map[string]interface{}{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    tokenType,
+		"expires_in":    expiresIn,
+	}}
+}
+
+// GenerateTestScenario starts a local httptest.Server returns steps in order,
+// one step per request, to either the device authorization endpoint authURL
+// or the token endpoint tokenURL. A request not matching the endpoint of the
+// next step, or after all steps are returned, gets 500 error, and a token
+// request without the device code of the last returned device code step gets
+// `invalid_grant` error. cleanup closes the server.
+//
+// The steps are not modified, hence they can be reused by other servers.
+func GenerateTestScenario(steps []Step) (authURL string, tokenURL string, cleanup func()) {
+	var mu sync.Mutex
+	next := 0
+	deviceCode := ""
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next >= len(steps) || steps[next].path != r.URL.Path {
+			writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+				"error":             "server_error",
+				"error_description": "unexpected request to " + r.URL.Path,
+			})
+			return
+		}
+		step := steps[next]
+		if step.path == "/token" && (r.PostFormValue("grant_type") != deviceGrantType || r.PostFormValue("device_code") != deviceCode) {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":             "invalid_grant",
+				"error_description": "invalid device code",
+			})
+			return
+		}
+		next++
+		body := step.body
+		if step.path == "/device" {
+			deviceCode, _ = body["device_code"].(string)
+			if body["verification_uri"] == "" {
+				body = make(map[string]interface{}, len(step.body))
+				for k, v := range step.body {
+					body[k] = v
+				}
+				body["verification_uri"] = "http://" + r.Host + "/verify"
+			}
+		}
+		writeJSON(w, step.status, body)
+	})
+	server := httptest.NewServer(handler)
+	return server.URL + "/device", server.URL + "/token", server.Close
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	//nolint:errcheck
+	json.NewEncoder(w).Encode(v)
+}
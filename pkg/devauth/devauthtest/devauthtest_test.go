@@ -0,0 +1,66 @@
+package devauthtest
+
+import (
+	"testing"
+
+	"github.com/tiewei/otoken/pkg/devauth"
+)
+
+func TestNewTestTokenSource(t *testing.T) {
+	src := NewTestTokenSource(t, Scenario{
+		PendingResponses: 1,
+		AccessToken:      "at",
+		RefreshToken:     "rt",
+		TokenType:        "Bearer",
+		ExpiresIn:        60,
+	})
+	token, err := src.Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "at" || token.RefreshToken != "rt" || token.TokenType != "Bearer" {
+		t.Errorf("Token() = %+v, want access token at, refresh token rt of type Bearer", token)
+	}
+}
+
+func TestGenerateTestScenario(t *testing.T) {
+	steps := []Step{
+		ReturnDeviceCode("device-code", devauth.UserCodeURI{UserCode: "CODE"}, 600, 1),
+		ReturnPollResponse("authorization_pending"),
+		ReturnToken("at", "rt", "Bearer", 60),
+	}
+	// the same steps are reused by the second server
+	for i := 0; i < 2; i++ {
+		authURL, tokenURL, cleanup := GenerateTestScenario(steps)
+		defer cleanup()
+		src := devauth.NewTokenSource(authURL, tokenURL, "test-client", nil,
+			devauth.UsePrompter(func(string, bool) {}),
+			devauth.UseURLOpener(func(string) {}),
+		)
+		token, err := src.Token()
+		if err != nil {
+			t.Fatalf("Token() returned error: %s", err)
+		}
+		if token.AccessToken != "at" {
+			t.Errorf("Token() = %+v, want access token at", token)
+		}
+	}
+	if uri := steps[0].body["verification_uri"]; uri != "" {
+		t.Errorf("steps are modified, verification_uri = %v", uri)
+	}
+}
+
+func TestGenerateTestScenarioAccessDenied(t *testing.T) {
+	authURL, tokenURL, cleanup := GenerateTestScenario([]Step{
+		ReturnDeviceCode("device-code", devauth.UserCodeURI{UserCode: "CODE"}, 600, 1),
+		ReturnPollResponse("access_denied"),
+	})
+	defer cleanup()
+	src := devauth.NewTokenSource(authURL, tokenURL, "test-client", nil,
+		devauth.UsePrompter(func(string, bool) {}),
+		devauth.UseURLOpener(func(string) {}),
+	)
+	if _, err := src.Token(); err == nil {
+		t.Fatal("Token() returned no error for access_denied")
+	}
+}
@@ -0,0 +1,110 @@
+package devauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tiewei/otoken/pkg/openid"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const defaultFallbackTimeout = time.Minute
+
+var errFallbackTimeout = errors.New("device authorization not completed in time, falling back to client credentials")
+
+// UseClientCredentialsFallback falls back to the client credentials grant
+// with clientSecret if the device authorization is not completed within the fallback
+// timeout, which is 1 minute by default and can be set by UseFallbackTimeout.
+// The fallback requests the scopes of the device authorization without `openid`,
+// which many providers reject for the client credentials grant.
+//
+// This is useful in environments where the user interaction is impossible, e.g. CI.
+// See WithClientCredentialsFallback to fall back when the device code
+// can't be requested.
+func UseClientCredentialsFallback(clientSecret string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.fallbackSecret = clientSecret
+		if s.fallbackTimeout == 0 {
+			s.fallbackTimeout = defaultFallbackTimeout
+		}
+	}}
+}
+
+// UseFallbackTimeout sets how long to wait for the device authorization
+// before falling back to the client credentials grant.
+func UseFallbackTimeout(t time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.fallbackTimeout = t
+	}}
+}
+
+// pollWithFallback polls the device token, and gets token by client
+// credentials grant if polling is not completed in the fallback timeout.
+func (s *TokenSource) pollWithFallback(ctx context.Context, client *http.Client, auth *Authorizor) (*oauth2.Token, error) {
+	if s.fallbackSecret == "" {
+		return s.pollToken(ctx, client, auth)
+	}
+	pollCtx, cancelFn := context.WithCancelCause(ctx)
+	defer cancelFn(nil)
+	timer := s.auth.clock.AfterFunc(s.fallbackTimeout, func() {
+		cancelFn(errFallbackTimeout)
+	})
+	defer timer.Stop()
+	token, err := s.pollToken(pollCtx, client, auth)
+	if err == nil || !errors.Is(err, errFallbackTimeout) {
+		return token, err
+	}
+	return s.clientCredentialsToken(ctx, client, s.fallbackSecret, openid.RemoveOpenIDScope(s.auth.scopes))
+}
+
+// WithClientCredentialsFallback falls back to the client credentials grant
+// with clientSecret and fallbackScopes if requesting the device code fails
+// with a network error, e.g. the device is offline during initial setup. Errors
+// returned by the authorization server don't trigger the fallback.
+//
+// See UseClientCredentialsFallback to fall back when the user doesn't
+// complete the authorization in time.
+func WithClientCredentialsFallback(clientSecret string, fallbackScopes []string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.offlineFallbackSecret = clientSecret
+		s.offlineFallbackScopes = fallbackScopes
+	}}
+}
+
+// requestCodeWithFallback requests the device code, and gets token by client
+// credentials grant if the request fails with network error. The returned
+// token is not nil only if it's from the fallback.
+func (s *TokenSource) requestCodeWithFallback(ctx context.Context, client *http.Client, auth *Authorizor) (*UserCodeURI, *oauth2.Token, error) {
+	userURI, err := auth.RequestCodeWithContext(ctx, client)
+	if err == nil || s.offlineFallbackSecret == "" {
+		return userURI, nil, err
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return nil, nil, err
+	}
+	log.Printf("failed to request device code: %s, falling back to client credentials grant", err)
+	token, err := s.clientCredentialsToken(ctx, client, s.offlineFallbackSecret, s.offlineFallbackScopes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("client credentials fallback failed: %w", err)
+	}
+	log.Printf("got fallback token by client credentials grant")
+	return nil, token, nil
+}
+
+// clientCredentialsToken gets token by client credentials grant.
+func (s *TokenSource) clientCredentialsToken(ctx context.Context, client *http.Client, secret string, scopes []string) (*oauth2.Token, error) {
+	cfg := clientcredentials.Config{
+		ClientID:     s.auth.clientID,
+		ClientSecret: secret,
+		TokenURL:     s.auth.tokenEndpoint,
+		Scopes:       scopes,
+	}
+	return cfg.Token(context.WithValue(ctx, oauth2.HTTPClient, client))
+}
@@ -0,0 +1,34 @@
+package devauth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// PollAttempt is a request made to the token endpoint while polling.
+type PollAttempt struct {
+	// Time is when the response is received.
+	Time time.Time
+	// Response is the raw response body, it's empty if no response is received.
+	Response string
+	// Error is the error of the attempt, nil if succeeded or still pending.
+	Error error
+}
+
+// PollHistory records all attempts made while polling the token.
+type PollHistory struct {
+	Attempts []PollAttempt
+}
+
+// PollWithHistory is the same as PollTokenWithContext,
+// but also returns the history of all poll attempts.
+func (d *Authorizor) PollWithHistory(ctx context.Context, client *http.Client) (*oauth2.Token, *PollHistory, error) {
+	history := &PollHistory{}
+	token, err := d.poll(ctx, client, func(attempt PollAttempt) {
+		history.Attempts = append(history.Attempts, attempt)
+	})
+	return token, history, err
+}
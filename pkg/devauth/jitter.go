@@ -0,0 +1,31 @@
+package devauth
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithPollingJitter randomizes each polling interval by ±fraction of it,
+// e.g. 0.1 for ±10%, so instances started at the same time don't poll
+// the token endpoint simultaneously. fraction is capped to 1.
+func WithPollingJitter(fraction float64) AuthorizorOption {
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.pollingJitter = fraction
+	}}
+}
+
+// jittered returns interval randomized by the polling jitter.
+func (d *Authorizor) jittered(interval time.Duration) time.Duration {
+	fraction := d.pollingJitter
+	if fraction <= 0 {
+		return interval
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	delta := time.Duration((rand.Float64()*2 - 1) * fraction * float64(interval))
+	if interval+delta <= 0 {
+		return interval
+	}
+	return interval + delta
+}
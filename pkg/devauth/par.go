@@ -0,0 +1,68 @@
+package devauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// parResponse is the pushed authorization response
+// https://datatracker.ietf.org/doc/html/rfc9126#section-2.2
+type parResponse struct {
+	RequestURI string         `json:"request_uri"`
+	ExpiresIn  expirationTime `json:"expires_in"`
+}
+
+// WithPARTokenPoll pushes the device authorization request, which starts the
+// token poll, to the pushed authorization request endpoint set by
+// WithPAREndpoint, and requests the device code with the returned
+// `request_uri` instead, to comply with providers enforcing PAR. Only the
+// authorization request is pushed as rfc9126 defines, the token endpoint
+// is polled as usual.
+func WithPARTokenPoll(enabled bool) AuthorizorOption {
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.parTokenPoll = enabled
+	}}
+}
+
+// WithPAREndpoint sets the pushed authorization request endpoint.
+func WithPAREndpoint(parEndpoint string) AuthorizorOption {
+	return &authorizorOption{applyFunc: func(d *Authorizor) {
+		d.parEndpoint = parEndpoint
+	}}
+}
+
+// pushAuthorizationRequest pushes the device authorization request params
+// to PAR endpoint, and returns the params referring to the pushed request.
+func (d *Authorizor) pushAuthorizationRequest(ctx context.Context, client *http.Client, params url.Values) (url.Values, error) {
+	if d.parEndpoint == "" {
+		return nil, errors.New("PAR endpoint must not be empty when PAR token poll is enabled")
+	}
+	resp, err := postForm(ctx, client, d.parEndpoint, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to push authorization request: response code %d, %s", resp.StatusCode, string(body))
+	}
+	data := &parResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return nil, err
+	}
+	if data.RequestURI == "" {
+		return nil, fmt.Errorf("%#v is not a valid pushed authorization response", data)
+	}
+	pushed, err := d.clientParams()
+	if err != nil {
+		return nil, err
+	}
+	pushed.Set("client_id", d.clientID)
+	pushed.Set("request_uri", data.RequestURI)
+	return pushed, nil
+}
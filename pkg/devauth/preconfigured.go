@@ -0,0 +1,30 @@
+package devauth
+
+// WithPreconfiguredCode uses the device code and userCodeURI instead of
+// requesting the device authorization endpoint, hence Token goes directly
+// to prompting the user and polling. It's useful in tests to mock only
+// the token endpoint. The option is ignored if userCodeURI is nil.
+func WithPreconfiguredCode(userCodeURI *UserCodeURI, deviceCode string, interval int32, expiresIn int32) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		if userCodeURI == nil {
+			return
+		}
+		s.preconfiguredCode = &deviceCodeResponse{
+			UserCodeURI: *userCodeURI,
+			DeviceCode:  deviceCode,
+			Interval:    expirationTime(interval),
+			ExpiresIn:   expirationTime(expiresIn),
+		}
+	}}
+}
+
+// usePreconfiguredCode saves a copy of code as if it's requested by
+// RequestCode, it returns error if a code has been requested.
+func (d *Authorizor) usePreconfiguredCode(code deviceCodeResponse) (*UserCodeURI, error) {
+	var userURI *UserCodeURI
+	err := errCodeRequested
+	d.requestOnce.Do(func() {
+		userURI, err = d.setCode(&code), nil
+	})
+	return userURI, err
+}
@@ -2,11 +2,18 @@ package devauth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/tiewei/otoken/pkg/openid"
 	"github.com/tiewei/otoken/pkg/types"
+	"golang.org/x/net/http2"
 	"golang.org/x/oauth2"
 )
 
@@ -20,6 +27,31 @@ type TokenSource struct {
 	prompter types.Prompter
 	opener   types.URLOpener
 	timeout  time.Duration
+
+	userAgent       string
+	dialTimeout     time.Duration
+	responseTimeout time.Duration
+	debugWriter     io.Writer
+	slogLevel       *slog.LevelVar
+	progressWriter  io.Writer
+	progressRune    rune
+
+	fallbackSecret  string
+	fallbackTimeout time.Duration
+
+	offlineFallbackSecret string
+	offlineFallbackScopes []string
+
+	endpoint             *openid.Endpoint
+	validateCapabilities bool
+	preconfiguredCode    *deviceCodeResponse
+	tokenValidator       func(*oauth2.Token) error
+	webhookURL           string
+	webhookHeaders       map[string]string
+	webhookClient        *http.Client
+
+	mu          sync.Mutex
+	lastUserURI *UserCodeURI
 }
 
 var _ oauth2.TokenSource = &TokenSource{}
@@ -60,6 +92,73 @@ func UseHTTPClient(c *http.Client) Option {
 	}}
 }
 
+// UseHTTP2Client uses a http client configured with HTTP/2 transport,
+// it falls back to HTTP/1.1 if the server doesn't support HTTP/2.
+func UseHTTP2Client() Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if _, err := http2.ConfigureTransports(transport); err != nil {
+			log.Printf("failed to configure http2 transport: %s", err)
+			return
+		}
+		s.client = &http.Client{Transport: transport}
+	}}
+}
+
+// UseUserAgent sets `User-Agent` header on all http requests.
+func UseUserAgent(ua string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.userAgent = ua
+	}}
+}
+
+// UseDialTimeout sets timeout of establishing connections,
+// it's independent from the overall Timeout.
+func UseDialTimeout(d time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.dialTimeout = d
+	}}
+}
+
+// UseResponseTimeout sets timeout of each http request,
+// it's independent from the overall Timeout.
+func UseResponseTimeout(d time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.responseTimeout = d
+	}}
+}
+
+// UseHTTPDebugLogger dumps all http requests and responses to w,
+// note the dump contains credentials like tokens.
+func UseHTTPDebugLogger(w io.Writer) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.debugWriter = w
+	}}
+}
+
+// UseProgressWriter writes a progress rune to w after each poll attempt,
+// and a newline when polling completes, it confirms the polling is alive.
+func UseProgressWriter(w io.Writer) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.progressWriter = w
+	}}
+}
+
+// UseProgressRune sets the rune written by UseProgressWriter, default is `.`.
+func UseProgressRune(r rune) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.progressRune = r
+	}}
+}
+
+// UseSlogLevelVar logs http requests at debug level to stderr, whether to
+// log is decided by levelVar, hence it can be adjusted at runtime.
+func UseSlogLevelVar(levelVar *slog.LevelVar) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.slogLevel = levelVar
+	}}
+}
+
 // Timeout sets additional timeout for the token polling process.
 func Timeout(t time.Duration) Option {
 	return &option{applyFunc: func(s *TokenSource) {
@@ -67,6 +166,88 @@ func Timeout(t time.Duration) Option {
 	}}
 }
 
+// UseClientAssertion authenticates the client by client assertion,
+// see WithClientAssertion.
+func UseClientAssertion(assertion func() (string, error), assertionType string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		WithClientAssertion(assertion, assertionType).applyAuthorizor(s.auth)
+	}}
+}
+
+// UseExtraScopes appends scopes to the base scope list,
+// duplicated scopes are removed, hence it's safe to use multiple times.
+func UseExtraScopes(scopes ...string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.auth.scopes = openid.MergeScopes(s.auth.scopes, scopes...)
+	}}
+}
+
+// UseFieldAliases renames non-standard fields in the device code response,
+// see WithFieldAliases.
+func UseFieldAliases(fieldMap map[string]string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		WithFieldAliases(fieldMap).applyAuthorizor(s.auth)
+	}}
+}
+
+// UseEndpoint sets the discovered provider metadata, which is used to
+// validate the provider capabilities.
+func UseEndpoint(endpoint *openid.Endpoint) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.endpoint = endpoint
+	}}
+}
+
+// ValidateCapabilities checks the provider advertises the device authorization
+// endpoint before starting the flow, it requires the metadata set by UseEndpoint.
+func ValidateCapabilities(enabled bool) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.validateCapabilities = enabled
+	}}
+}
+
+// UsePARTokenPoll pushes the device authorization request to parEndpoint
+// before requesting the device code, see WithPARTokenPoll.
+func UsePARTokenPoll(parEndpoint string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		WithPAREndpoint(parEndpoint).applyAuthorizor(s.auth)
+		WithPARTokenPoll(true).applyAuthorizor(s.auth)
+	}}
+}
+
+// UseExternalRedirectURI sends uri as `redirect_uri` in the device
+// authorization request, see WithRedirectURI.
+func UseExternalRedirectURI(uri string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		WithRedirectURI(uri).applyAuthorizor(s.auth)
+	}}
+}
+
+// UsePollingJitter randomizes each polling interval by ±fraction of it,
+// see WithPollingJitter.
+func UsePollingJitter(fraction float64) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		WithPollingJitter(fraction).applyAuthorizor(s.auth)
+	}}
+}
+
+// WithTokenValidator calls fn with the acquired token before returning it,
+// e.g. to check the `aud` or `iss` claims, Token returns the error of fn
+// instead of the token if it fails.
+func WithTokenValidator(fn func(*oauth2.Token) error) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.tokenValidator = fn
+	}}
+}
+
+// UseClock sets the clock used while polling and by the fallback timeout,
+// see WithClock.
+func UseClock(c Clock) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		WithClock(c).applyAuthorizor(s.auth)
+	}}
+}
+
 // NewTokenSource creates a new device auth token source.
 // It by default uses `http.DefaultClient` as http client
 // `types.StdoutPrompter` as prompter and `types.BrowserOpener`
@@ -78,6 +259,8 @@ func NewTokenSource(authEndpoint string, tokenEndpoint string, clientID string,
 		client:   http.DefaultClient,
 		prompter: types.StdoutPrompter,
 		opener:   types.BrowserOpener,
+
+		progressRune: '.',
 	}
 	for _, op := range opts {
 		if op != nil {
@@ -87,18 +270,65 @@ func NewTokenSource(authEndpoint string, tokenEndpoint string, clientID string,
 	return s
 }
 
+// httpClient returns the http client configured by options.
+func (s *TokenSource) httpClient() *http.Client {
+	client := types.WithTimeouts(s.client, s.dialTimeout, s.responseTimeout)
+	client = types.WithDebugLogger(client, s.debugWriter)
+	client = types.WithSlogLevelVar(client, s.slogLevel)
+	return types.WithUserAgent(client, s.userAgent)
+}
+
+// validate checks the provider capabilities required by the flow.
+func (s *TokenSource) validate() error {
+	if !s.validateCapabilities {
+		return nil
+	}
+	if s.endpoint == nil {
+		return errors.New("provider metadata is required to validate capabilities")
+	}
+	if s.endpoint.DeviceAuthURL == "" {
+		return errors.New("provider does not support device authorization")
+	}
+	return nil
+}
+
 // Token creates a new auth2.Token by going through the device auth process.
+// Use types.IsDPoP to check if the token is DPoP-bound.
 func (s *TokenSource) Token() (*oauth2.Token, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
 	ctx := context.Background()
 	if s.timeout > 0 {
 		var cancelFunc context.CancelFunc
 		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
 		defer cancelFunc()
 	}
-	userURI, err := s.auth.RequestCode(ctx, s.client)
+	client := s.httpClient()
+	// a new Authorizor per Token call, since the device code can only be requested once
+	auth := s.auth.clone()
+	var userURI *UserCodeURI
+	var fallbackToken *oauth2.Token
+	var err error
+	if s.preconfiguredCode != nil {
+		userURI, err = auth.usePreconfiguredCode(*s.preconfiguredCode)
+	} else {
+		userURI, fallbackToken, err = s.requestCodeWithFallback(ctx, client, auth)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if fallbackToken != nil {
+		return s.validateToken(fallbackToken)
+	}
+	s.mu.Lock()
+	s.lastUserURI = userURI
+	s.mu.Unlock()
+	if s.webhookURL != "" {
+		if err := s.notifyWebhook(ctx, userURI); err != nil {
+			log.Printf("failed to notify webhook: %s", err)
+		}
+	}
 	if len(userURI.VerificationURIComplete) == 0 {
 		s.prompter(fmt.Sprintf("Please copy one-time code: %s", userURI.UserCode), true)
 		s.opener(userURI.VerificationURI)
@@ -106,5 +336,51 @@ func (s *TokenSource) Token() (*oauth2.Token, error) {
 		s.opener(userURI.VerificationURIComplete)
 	}
 
-	return s.auth.PollToken(ctx, s.client)
+	token, err := s.pollWithFallback(ctx, client, auth)
+	if err != nil {
+		return nil, err
+	}
+	return s.validateToken(token)
+}
+
+// validateToken validates token by the validator set by WithTokenValidator.
+func (s *TokenSource) validateToken(token *oauth2.Token) (*oauth2.Token, error) {
+	if s.tokenValidator == nil {
+		return token, nil
+	}
+	if err := s.tokenValidator(token); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	return token, nil
+}
+
+// pollToken polls the token, and writes the progress if required.
+func (s *TokenSource) pollToken(ctx context.Context, client *http.Client, auth *Authorizor) (*oauth2.Token, error) {
+	if s.progressWriter == nil {
+		return auth.PollTokenWithContext(ctx, client)
+	}
+	defer fmt.Fprintln(s.progressWriter)
+	return auth.poll(ctx, client, func(PollAttempt) {
+		fmt.Fprint(s.progressWriter, string(s.progressRune))
+	})
+}
+
+// LastUserCodeURI returns a copy of the last UserCodeURI received
+// while calling Token(), or nil if no code has been requested yet.
+func (s *TokenSource) LastUserCodeURI() *UserCodeURI {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastUserURI == nil {
+		return nil
+	}
+	userURI := *s.lastUserURI
+	return &userURI
+}
+
+// Endpoint returns the endpoints the TokenSource is configured to use.
+func (s *TokenSource) Endpoint() openid.Endpoint {
+	return openid.Endpoint{
+		TokenURL:      s.auth.tokenEndpoint,
+		DeviceAuthURL: s.auth.authEndpoint,
+	}
 }
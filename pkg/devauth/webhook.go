@@ -0,0 +1,78 @@
+package devauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// UseWebhookNotifier posts the UserCodeURI as JSON to webhookURL after the
+// device code is requested, so a human can be notified out-of-band, e.g. by
+// Slack or email. headers are set on the request, e.g. for authentication.
+// The payload carries `expires_at` in RFC3339 besides the UserCodeURI fields.
+// A failed notification is only logged.
+//
+// The webhook is called with its own http client, not the one talking to the
+// authorization server, so headers are never written by the debug logger,
+// use UseWebhookHTTPClient to change it.
+func UseWebhookNotifier(webhookURL string, headers map[string]string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.webhookURL = webhookURL
+		s.webhookHeaders = headers
+	}}
+}
+
+// UseWebhookHTTPClient sets the http client used by UseWebhookNotifier,
+// defaults to a client with 10 seconds timeout.
+func UseWebhookHTTPClient(c *http.Client) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.webhookClient = c
+	}}
+}
+
+// defaultWebhookTimeout limits the webhook call when no client is set.
+const defaultWebhookTimeout = 10 * time.Second
+
+// webhookPayload is the JSON body posted to the webhook.
+type webhookPayload struct {
+	*UserCodeURI
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// notifyWebhook posts userURI to the webhook.
+func (s *TokenSource) notifyWebhook(ctx context.Context, userURI *UserCodeURI) error {
+	payload := webhookPayload{UserCodeURI: userURI}
+	if !userURI.ExpiresAt.IsZero() {
+		payload.ExpiresAt = userURI.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.webhookHeaders {
+		req.Header.Set(key, value)
+	}
+	client := s.webhookClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("response code %d, %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Package oauthtest provides a fake OAuth2 endpoint shared by the tests
+// of the grant packages, each test checks the requests of its own grant.
+package oauthtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Response is the response of the fake endpoint.
+type Response struct {
+	Status      int
+	ContentType string
+	Body        string
+}
+
+// JSON returns a Response of status with v encoded as the JSON body.
+func JSON(status int, v interface{}) Response {
+	body, _ := json.Marshal(v)
+	return Response{Status: status, ContentType: "application/json", Body: string(body)}
+}
+
+// Text returns a Response of status with the plain text body.
+func Text(status int, body string) Response {
+	return Response{Status: status, ContentType: "text/plain", Body: body}
+}
+
+// NewServer starts a fake endpoint responds every request by respond, the
+// form of the request is parsed before respond is called. The server is
+// closed when the test finishes.
+func NewServer(t testing.TB, respond func(r *http.Request) Response) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+		}
+		resp := respond(r)
+		w.Header().Set("Content-Type", resp.ContentType)
+		w.WriteHeader(resp.Status)
+		//nolint:errcheck
+		w.Write([]byte(resp.Body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// ClientCredentials returns the client ID and secret of r, from HTTP Basic
+// auth if set, otherwise from the form, basic tells which one is used.
+func ClientCredentials(r *http.Request) (clientID string, secret string, basic bool) {
+	if clientID, secret, ok := r.BasicAuth(); ok {
+		return clientID, secret, true
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), false
+}
@@ -0,0 +1,165 @@
+// Package introspect implements the OAuth2 token introspection
+// described in rfc7662, which checks whether a token is active
+// and returns its metadata.
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tiewei/otoken/pkg/openid"
+)
+
+// Result is the introspection response, only Active is required,
+// the other fields are set if the token is active.
+type Result struct {
+	Active    bool            `json:"active"`
+	Scope     string          `json:"scope,omitempty"`
+	ClientID  string          `json:"client_id,omitempty"`
+	Username  string          `json:"username,omitempty"`
+	TokenType string          `json:"token_type,omitempty"`
+	Exp       int64           `json:"exp,omitempty"`
+	Iat       int64           `json:"iat,omitempty"`
+	Nbf       int64           `json:"nbf,omitempty"`
+	Sub       string          `json:"sub,omitempty"`
+	Aud       openid.Audience `json:"aud,omitempty"`
+	Iss       string          `json:"iss,omitempty"`
+	Jti       string          `json:"jti,omitempty"`
+}
+
+// Expiry returns the expiry time of the token, or zero time if `exp` is omitted.
+func (r *Result) Expiry() time.Time {
+	if r.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(r.Exp, 0)
+}
+
+// Option configures optional field for Introspector,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type Option interface {
+	apply(*Introspector)
+}
+
+type option struct {
+	applyFunc func(*Introspector)
+}
+
+func (o option) apply(i *Introspector) {
+	o.applyFunc(i)
+}
+
+// UseHTTPClient sets http client used to make http requests.
+func UseHTTPClient(c *http.Client) Option {
+	return &option{applyFunc: func(i *Introspector) {
+		i.client = c
+	}}
+}
+
+// UseTokenTypeHint sets the `token_type_hint` of the request,
+// e.g. `access_token` or `refresh_token`.
+func UseTokenTypeHint(hint string) Option {
+	return &option{applyFunc: func(i *Introspector) {
+		i.tokenTypeHint = hint
+	}}
+}
+
+// Client authentication methods supported by UseAuthMethod.
+const (
+	AuthMethodBasic = "client_secret_basic"
+	AuthMethodPost  = "client_secret_post"
+)
+
+// UseAuthMethod sets how the client authenticates to the introspection
+// endpoint, AuthMethodBasic or AuthMethodPost, an empty method keeps the
+// default AuthMethodBasic. Use openid.SelectIntrospectionAuthMethod to pick
+// one supported by the provider.
+func UseAuthMethod(method string) Option {
+	return &option{applyFunc: func(i *Introspector) {
+		if method != "" {
+			i.authMethod = method
+		}
+	}}
+}
+
+// Introspector sends tokens to the introspection endpoint,
+// authenticated by the client ID and secret, with HTTP Basic auth
+// unless changed by UseAuthMethod.
+type Introspector struct {
+	introspectionURL string
+	clientID         string
+	clientSecret     string
+
+	authMethod    string
+	tokenTypeHint string
+	client        *http.Client
+}
+
+// New creates a new Introspector.
+// It by default uses `http.DefaultClient` as http client,
+// to change it, set Options when creating the instance.
+func New(introspectionURL string, clientID string, clientSecret string, opts ...Option) *Introspector {
+	i := &Introspector{
+		introspectionURL: introspectionURL,
+		clientID:         clientID,
+		clientSecret:     clientSecret,
+		authMethod:       AuthMethodBasic,
+		client:           http.DefaultClient,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(i)
+		}
+	}
+	return i
+}
+
+// Introspect sends token to the introspection endpoint and returns the result.
+// An inactive token is not an error, check Result.Active instead.
+func (i *Introspector) Introspect(ctx context.Context, token string) (*Result, error) {
+	values := url.Values{"token": {token}}
+	if i.tokenTypeHint != "" {
+		values.Set("token_type_hint", i.tokenTypeHint)
+	}
+	switch i.authMethod {
+	case AuthMethodBasic:
+	case AuthMethodPost:
+		values.Set("client_id", i.clientID)
+		values.Set("client_secret", i.clientSecret)
+	default:
+		return nil, fmt.Errorf("unsupported introspection auth method %s", i.authMethod)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.introspectionURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.authMethod == AuthMethodBasic {
+		req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to introspect token: response code %d, %s", resp.StatusCode, string(body))
+	}
+	result := &Result{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("failed to decode introspection response: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package introspect
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tiewei/otoken/pkg/internal/oauthtest"
+)
+
+func TestIntrospect(t *testing.T) {
+	introspectionURL := oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		if got := r.PostForm.Get("token_type_hint"); got != "access_token" {
+			t.Errorf("token_type_hint = %q, want access_token", got)
+		}
+		if id, secret, basic := oauthtest.ClientCredentials(r); !basic || id != "client" || secret != "secret" {
+			t.Errorf("basic auth = %q:%q, want client:secret", id, secret)
+		}
+		if r.PostForm.Get("token") != "active-token" {
+			return oauthtest.JSON(http.StatusOK, map[string]bool{"active": false})
+		}
+		return oauthtest.JSON(http.StatusOK, map[string]interface{}{
+			"active": true, "client_id": "client", "sub": "alice", "exp": 1700000000, "aud": []string{"api", "web"},
+		})
+	}).URL
+	introspector := New(introspectionURL, "client", "secret", UseTokenTypeHint("access_token"))
+
+	result, err := introspector.Introspect(context.Background(), "active-token")
+	if err != nil {
+		t.Fatalf("Introspect() returned error: %s", err)
+	}
+	if !result.Active || result.Sub != "alice" || result.Expiry().Unix() != 1700000000 || !result.Aud.Contains("web") {
+		t.Errorf("Introspect() = %+v, want active token of alice for web", result)
+	}
+
+	// an inactive token is a result rather than an error
+	result, err = introspector.Introspect(context.Background(), "revoked-token")
+	if err != nil {
+		t.Fatalf("Introspect() returned error for inactive token: %s", err)
+	}
+	if result.Active || result.Sub != "" || !result.Expiry().IsZero() {
+		t.Errorf("Introspect() = %+v, want inactive token without metadata", result)
+	}
+}
+
+func TestIntrospectAuthMethodPost(t *testing.T) {
+	introspectionURL := oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		if id, secret, basic := oauthtest.ClientCredentials(r); basic || id != "client" || secret != "secret" {
+			t.Errorf("client_secret_post got basic auth %t, credentials %q:%q", basic, id, secret)
+		}
+		return oauthtest.JSON(http.StatusOK, map[string]bool{"active": true})
+	}).URL
+	result, err := New(introspectionURL, "client", "secret", UseAuthMethod(AuthMethodPost)).Introspect(context.Background(), "token")
+	if err != nil || !result.Active {
+		t.Errorf("Introspect() = %+v, %v, want active token", result, err)
+	}
+}
+
+func TestIntrospectError(t *testing.T) {
+	introspectionURL := oauthtest.NewServer(t, func(*http.Request) oauthtest.Response {
+		return oauthtest.Text(http.StatusUnauthorized, "bad client")
+	}).URL
+	_, err := New(introspectionURL, "client", "wrong").Introspect(context.Background(), "token")
+	if err == nil || !strings.Contains(err.Error(), "response code 401, bad client") {
+		t.Errorf("Introspect() error = %v, want response code 401", err)
+	}
+	_, err = New(introspectionURL, "client", "secret", UseAuthMethod("private_key_jwt")).Introspect(context.Background(), "token")
+	if err == nil || !strings.Contains(err.Error(), "unsupported introspection auth method") {
+		t.Errorf("Introspect() error = %v, want unsupported auth method", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package jwtbearer implements the OAuth2 JWT bearer grant described
+// in rfc7523. The client gets access token by a JWT assertion signed
+// by its private key, instead of a shared secret.
+package jwtbearer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/tiewei/otoken/pkg/openid"
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+// GrantType is the grant type used by JWT bearer requests.
+const GrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+const defaultAssertionLifetime = 5 * time.Minute
+
+// Option configures optional field for TokenSource,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type Option interface {
+	apply(*TokenSource)
+}
+
+type option struct {
+	applyFunc func(*TokenSource)
+}
+
+func (o option) apply(s *TokenSource) {
+	o.applyFunc(s)
+}
+
+// UseHTTPClient sets http client used to make http requests.
+func UseHTTPClient(c *http.Client) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.client = c
+	}}
+}
+
+// Timeout sets additional timeout for the token request.
+func Timeout(t time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.timeout = t
+	}}
+}
+
+// UseScopes sets the scopes of the requested token.
+func UseScopes(scopes []string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.scopes = scopes
+	}}
+}
+
+// UseAudience sets the `aud` claim of the assertion,
+// it's the token endpoint by default.
+func UseAudience(audience string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.audience = audience
+	}}
+}
+
+// UseKeyID sets the `kid` header of the assertion.
+func UseKeyID(keyID string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.keyID = keyID
+	}}
+}
+
+// UseAssertionLifetime sets how long the assertion is valid, default is 5 minutes.
+func UseAssertionLifetime(d time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.assertionLifetime = d
+	}}
+}
+
+// TokenSource implements oauth2.TokenSource interface
+// to provide token via JWT bearer grant described in rfc7523.
+type TokenSource struct {
+	tokenEndpoint string
+	clientID      string
+	key           crypto.Signer
+	subject       string
+
+	scopes            []string
+	audience          string
+	keyID             string
+	assertionLifetime time.Duration
+	client            *http.Client
+	timeout           time.Duration
+}
+
+var _ oauth2.TokenSource = &TokenSource{}
+
+// NewTokenSource creates a new JWT bearer token source, the assertion
+// is issued by clientID for subject, and signed by the RSA or ECDSA key.
+// It by default uses `http.DefaultClient` as http client,
+// to change it, set Options when creating the instance.
+func NewTokenSource(tokenEndpoint string, clientID string, key crypto.Signer, subject string, opts ...Option) *TokenSource {
+	s := &TokenSource{
+		tokenEndpoint: tokenEndpoint,
+		clientID:      clientID,
+		key:           key,
+		subject:       subject,
+
+		assertionLifetime: defaultAssertionLifetime,
+		client:            http.DefaultClient,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(s)
+		}
+	}
+	return s
+}
+
+// NewTokenSourceFromKeyFile is the same as NewTokenSource,
+// but loads the key from the PEM file at keyFile.
+func NewTokenSourceFromKeyFile(tokenEndpoint string, clientID string, keyFile string, subject string, opts ...Option) (*TokenSource, error) {
+	key, err := LoadPrivateKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewTokenSource(tokenEndpoint, clientID, key, subject, opts...), nil
+}
+
+// assertion creates the signed JWT assertion.
+func (s *TokenSource) assertion() (string, error) {
+	alg, err := signatureAlgorithm(s.key)
+	if err != nil {
+		return "", err
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	audience := s.audience
+	if audience == "" {
+		audience = s.tokenEndpoint
+	}
+	now := time.Now()
+	payload, err := json.Marshal(map[string]interface{}{
+		"iss": s.clientID,
+		"sub": s.subject,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(s.assertionLifetime).Unix(),
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+	})
+	if err != nil {
+		return "", err
+	}
+	signer, err := openid.NewJWTSigner(s.key, s.keyID, alg, "JWT")
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign assertion: %w", err)
+	}
+	return signed.CompactSerialize()
+}
+
+// Token requests a new oauth2.Token by a new JWT assertion.
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, s.client)
+	if s.timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
+		defer cancelFunc()
+	}
+	assertion, err := s.assertion()
+	if err != nil {
+		return nil, err
+	}
+	// the token request is the same as client credentials request except the
+	// grant type and assertion, and the client is identified by client_id
+	cfg := clientcredentials.Config{
+		ClientID: s.clientID,
+		TokenURL: s.tokenEndpoint,
+		Scopes:   s.scopes,
+		EndpointParams: url.Values{
+			"grant_type": {GrantType},
+			"assertion":  {assertion},
+		},
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+	token, err := cfg.Token(ctx)
+	return token, types.TokenError(err)
+}
@@ -0,0 +1,88 @@
+package jwtbearer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+
+	"github.com/tiewei/otoken/pkg/internal/oauthtest"
+)
+
+func TestToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	var claims map[string]interface{}
+	var header jose.Header
+	tokenURL := oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		if got := r.PostForm.Get("grant_type"); got != GrantType {
+			t.Errorf("grant_type = %q, want %q", got, GrantType)
+		}
+		if id, _, basic := oauthtest.ClientCredentials(r); basic || id != "client" {
+			t.Errorf("client must be identified by client_id only, got basic auth %t, client_id %q", basic, id)
+		}
+		if got := r.PostForm.Get("scope"); got != "read" {
+			t.Errorf("scope = %q, want read", got)
+		}
+		jws, err := jose.ParseSigned(r.PostForm.Get("assertion"))
+		if err != nil {
+			t.Errorf("assertion is not a JWS: %s", err)
+			return oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		}
+		header = jws.Signatures[0].Header
+		payload, err := jws.Verify(&key.PublicKey)
+		if err != nil {
+			t.Errorf("assertion is not signed by the key: %s", err)
+			return oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			t.Errorf("assertion claims are not JSON: %s", err)
+			return oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		}
+		return oauthtest.JSON(http.StatusOK, map[string]interface{}{"access_token": "at", "token_type": "Bearer", "expires_in": 60})
+	}).URL
+
+	token, err := NewTokenSource(tokenURL, "client", key, "service-account", UseScopes([]string{"read"}), UseKeyID("kid-1")).Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "at" {
+		t.Errorf("Token() = %+v, want access token at", token)
+	}
+	if header.Algorithm != string(jose.ES256) || header.KeyID != "kid-1" {
+		t.Errorf("assertion header alg %s, kid %s, want ES256 and kid-1", header.Algorithm, header.KeyID)
+	}
+	if claims["iss"] != "client" || claims["sub"] != "service-account" || claims["aud"] != tokenURL {
+		t.Errorf("assertion claims = %v, want iss client, sub service-account and aud of the token endpoint", claims)
+	}
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if lifetime := time.Duration(exp-iat) * time.Second; lifetime != defaultAssertionLifetime {
+		t.Errorf("assertion lifetime = %s, want %s", lifetime, defaultAssertionLifetime)
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		t.Error("assertion has no jti")
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	tokenURL := oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		return oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "audience mismatch"})
+	}).URL
+	_, err = NewTokenSource(tokenURL, "client", key, "service-account").Token()
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant, audience mismatch") {
+		t.Errorf("Token() error = %v, want invalid_grant", err)
+	}
+}
@@ -0,0 +1,41 @@
+package openid
+
+import (
+	"errors"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// BuildAuthorizationURL builds the authorization URL for the authorization
+// code flow without starting any local server, the caller is responsible
+// for redirecting user to the URL and handling the callback.
+func BuildAuthorizationURL(endpoint *Endpoint, clientID string, redirectURI string, scopes []string, state string, opts ...oauth2.AuthCodeOption) (string, error) {
+	if endpoint == nil || endpoint.AuthURL == "" {
+		return "", errors.New("authorization endpoint must not be empty")
+	}
+	if clientID == "" {
+		return "", errors.New("client id must not be empty")
+	}
+	if state == "" {
+		return "", errors.New("state must not be empty")
+	}
+	if _, err := url.Parse(endpoint.AuthURL); err != nil {
+		return "", err
+	}
+	if redirectURI != "" {
+		if _, err := url.Parse(redirectURI); err != nil {
+			return "", err
+		}
+	}
+	cfg := oauth2.Config{
+		ClientID:    clientID,
+		RedirectURL: redirectURI,
+		Scopes:      EnsureOpenIDScope(scopes),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  endpoint.AuthURL,
+			TokenURL: endpoint.TokenURL,
+		},
+	}
+	return cfg.AuthCodeURL(state, opts...), nil
+}
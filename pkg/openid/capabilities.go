@@ -0,0 +1,91 @@
+package openid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SupportsResponseType reports whether the provider advertises responseType
+// in `response_types_supported`, the order of space separated values is ignored.
+func SupportsResponseType(endpoint *Endpoint, responseType string) bool {
+	if endpoint == nil {
+		return false
+	}
+	want := normalizeResponseType(responseType)
+	for _, rt := range endpoint.ResponseTypesSupported {
+		if normalizeResponseType(rt) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeResponseType(responseType string) string {
+	values := strings.Fields(responseType)
+	sort.Strings(values)
+	return strings.Join(values, " ")
+}
+
+// SupportsPKCE reports whether the provider supports the authorization code
+// grant with `S256` code challenge method. The grant types default to
+// `authorization_code` and `implicit` if omitted, according to rfc8414.
+func SupportsPKCE(endpoint *Endpoint) bool {
+	if endpoint == nil {
+		return false
+	}
+	grantTypes := endpoint.GrantTypesSupported
+	if len(grantTypes) == 0 {
+		grantTypes = []string{"authorization_code", "implicit"}
+	}
+	return contains(grantTypes, "authorization_code") && contains(endpoint.CodeChallengeMethodsSupported, "S256")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAuthMethod returns error if the provider doesn't advertise the token
+// endpoint client authentication method, e.g. `client_secret_basic`. The methods
+// default to `client_secret_basic` if omitted, according to rfc8414.
+func RequireAuthMethod(endpoint *Endpoint, method string) error {
+	if endpoint == nil {
+		return fmt.Errorf("no provider metadata to check token endpoint auth method %s", method)
+	}
+	methods := endpoint.TokenEndpointAuthMethods
+	if len(methods) == 0 {
+		methods = []string{"client_secret_basic"}
+	}
+	if !contains(methods, method) {
+		return fmt.Errorf("token endpoint auth method %s is not supported by provider, supported methods: %s", method, strings.Join(methods, ", "))
+	}
+	return nil
+}
+
+// SelectIntrospectionAuthMethod returns the first method in preferred supported
+// by the introspection endpoint (rfc7662), or empty string if none is supported.
+// rfc8414 doesn't define a default for the introspection endpoint, hence the
+// token endpoint auth methods are used if the introspection ones are omitted.
+func SelectIntrospectionAuthMethod(endpoint *Endpoint, preferred []string) string {
+	if endpoint == nil {
+		return ""
+	}
+	methods := endpoint.IntrospectionAuthMethodsSupported
+	if len(methods) == 0 {
+		methods = endpoint.TokenEndpointAuthMethods
+	}
+	if len(methods) == 0 {
+		methods = []string{"client_secret_basic"}
+	}
+	for _, method := range preferred {
+		if contains(methods, method) {
+			return method
+		}
+	}
+	return ""
+}
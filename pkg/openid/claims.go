@@ -0,0 +1,55 @@
+package openid
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseTokenClaims decodes the payload of rawJWT and unmarshals the claims into dst.
+//
+// It does NOT verify the signature of the token, hence the claims must not be
+// trusted unless the token is verified elsewhere, e.g. received from the token
+// endpoint directly over TLS.
+func ParseTokenClaims[T any](rawJWT string, dst *T) error {
+	parts := strings.Split(rawJWT, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed jwt, expected 3 parts got %d", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fmt.Errorf("malformed jwt payload: %w", err)
+	}
+	if err := json.Unmarshal(payload, dst); err != nil {
+		return fmt.Errorf("failed to unmarshal claims: %w", err)
+	}
+	return nil
+}
+
+// Audience is the `aud` claim, which can be either a string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// Contains reports whether aud is one of the audiences.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
@@ -2,17 +2,89 @@ package openid
 
 import (
 	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
 
 	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
 )
 
 // Endpoint contains auth endpoints.
 type Endpoint struct {
+	Issuer        string `json:"issuer"`
 	TokenURL      string `json:"token_endpoint"`
 	AuthURL       string `json:"authorization_endpoint"`
 	DeviceAuthURL string `json:"device_authorization_endpoint"`
+
+	// IntrospectionURL is the token introspection endpoint (rfc7662).
+	IntrospectionURL string `json:"introspection_endpoint"`
+
+	// BackchannelAuthenticationEndpoint is the backchannel authentication
+	// endpoint of OpenID Connect CIBA flow, see the ciba package.
+	BackchannelAuthenticationEndpoint string `json:"backchannel_authentication_endpoint"`
+
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	TokenEndpointAuthMethods          []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+
+	// RequireRequestObject is set if the provider requires the authorization
+	// request to be sent as a request object (rfc9101), it's also set by
+	// `require_request_uri_registration`, see BuildRequestJWT.
+	RequireRequestObject bool `json:"require_signed_request_object"`
+
+	// Extra contains claims of the discovery document not known by Endpoint,
+	// e.g. provider-specific extension fields.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON unmarshals the discovery document into Endpoint,
+// and collects unknown claims into Extra.
+func (e *Endpoint) UnmarshalJSON(b []byte) error {
+	// endpoint has no methods, hence avoids recursive UnmarshalJSON
+	type endpoint Endpoint
+	known := endpoint{}
+	if err := json.Unmarshal(b, &known); err != nil {
+		return err
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	for _, key := range knownClaims() {
+		delete(raw, key)
+	}
+	*e = Endpoint(known)
+	if registration, ok := raw["require_request_uri_registration"]; ok && string(registration) == "true" {
+		e.RequireRequestObject = true
+	}
+	if len(raw) > 0 {
+		e.Extra = raw
+	}
+	return nil
 }
 
+// knownClaims returns claim names mapped to Endpoint fields.
+func knownClaims() []string {
+	t := reflect.TypeOf(Endpoint{})
+	claims := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			claims = append(claims, name)
+		}
+	}
+	return claims
+}
+
+// Discover fetches the discovery document of IssuerURI and returns its
+// endpoints. The discovery request is sent by the http.Client set in ctx
+// by `oidc.ClientContext` or as the `oauth2.HTTPClient` value, otherwise
+// http.DefaultClient is used, see DiscoverWithClient.
 func Discover(ctx context.Context, IssuerURI string) (*Endpoint, error) {
 	provider, err := gooidc.NewProvider(ctx, IssuerURI)
 	if err != nil {
@@ -24,3 +96,110 @@ func Discover(ctx context.Context, IssuerURI string) (*Endpoint, error) {
 	}
 	return endpoint, nil
 }
+
+// DiscoverWithClient is the same as Discover, but sends the discovery
+// request by client, e.g. a client with custom transport or timeout.
+func DiscoverWithClient(ctx context.Context, issuerURI string, client *http.Client) (*Endpoint, error) {
+	return Discover(gooidc.ClientContext(ctx, client), issuerURI)
+}
+
+// responseRecorder records the response of the discovery request.
+type responseRecorder struct {
+	base         http.RoundTripper
+	discoveryURL string
+	resp         *http.Response
+}
+
+func (t *responseRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && req.URL.String() == t.discoveryURL {
+		t.resp = resp
+	}
+	return resp, err
+}
+
+// DiscoverWithResponse is the same as DiscoverWithClient, but also returns the
+// response of the discovery request, e.g. to honour its `Cache-Control` header.
+// The response body is already closed. The response is returned along with
+// the error if the document is received but invalid.
+func DiscoverWithResponse(ctx context.Context, issuerURI string, client *http.Client) (*Endpoint, *http.Response, error) {
+	discoveryURL, err := WellKnownURL(issuerURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	recorder := &responseRecorder{base: base, discoveryURL: discoveryURL}
+	c := *client
+	c.Transport = recorder
+	endpoint, err := DiscoverWithClient(ctx, issuerURI, &c)
+	return endpoint, recorder.resp, err
+}
+
+// DiscoverViaSocket discovers endpoints of issuerURI by connecting to the
+// Unix domain socket at socketPath instead of the issuer host, the issuerURI
+// is still used as the request URL and `Host` header.
+func DiscoverViaSocket(ctx context.Context, issuerURI string, socketPath string) (*Endpoint, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	return DiscoverWithClient(ctx, issuerURI, client)
+}
+
+// headerTransport sets headers on the discovery request.
+type headerTransport struct {
+	base         http.RoundTripper
+	headers      http.Header
+	discoveryURL string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.String() == t.discoveryURL {
+		req = req.Clone(req.Context())
+		for key, values := range t.headers {
+			req.Header.Del(key)
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// DiscoverWithHeaders is the same as Discover, but sets headers
+// (e.g. a custom `Accept` header) on the discovery request only.
+func DiscoverWithHeaders(ctx context.Context, issuerURI string, headers http.Header) (*Endpoint, error) {
+	discoveryURL, err := WellKnownURL(issuerURI)
+	if err != nil {
+		return nil, err
+	}
+	base := http.DefaultTransport
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && c.Transport != nil {
+		base = c.Transport
+	}
+	client := &http.Client{
+		Transport: &headerTransport{
+			base:         base,
+			headers:      headers,
+			discoveryURL: discoveryURL,
+		},
+	}
+	return Discover(gooidc.ClientContext(ctx, client), issuerURI)
+}
+
+// DiscoverWithAuth is the same as Discover, but sets authHeader as the
+// `Authorization` header of the discovery request, for providers require
+// authentication to access the discovery endpoint.
+func DiscoverWithAuth(ctx context.Context, issuerURI string, authHeader string) (*Endpoint, error) {
+	return DiscoverWithHeaders(ctx, issuerURI, http.Header{"Authorization": {authHeader}})
+}
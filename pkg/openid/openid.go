@@ -11,3 +11,29 @@ func EnsureOpenIDScope(scopes []string) []string {
 	}
 	return append(scopes, gooidc.ScopeOpenID)
 }
+
+// MergeScopes returns a new scope list with extra scopes appended to
+// the base scopes, duplicated scopes are removed.
+func MergeScopes(base []string, extra ...string) []string {
+	merged := make([]string, 0, len(base)+len(extra))
+	seen := make(map[string]bool, len(base)+len(extra))
+	for _, s := range append(append([]string{}, base...), extra...) {
+		if !seen[s] {
+			seen[s] = true
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// RemoveOpenIDScope returns a new scope list without `openid`, for grants
+// that don't issue ID token, e.g. client credentials.
+func RemoveOpenIDScope(scopes []string) []string {
+	removed := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		if s != gooidc.ScopeOpenID {
+			removed = append(removed, s)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,83 @@
+package openid
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"golang.org/x/oauth2"
+)
+
+// requestObjectLifetime is how long the request object is valid.
+const requestObjectLifetime = 5 * time.Minute
+
+// BuildRequestJWT signs the authorization request parameters of cfg as a
+// request object described in rfc9101, params are additional parameters of
+// the request, e.g. `state`, `nonce` and `code_challenge`. The audience is
+// the issuer of endpoint, or its authorization endpoint if the issuer is unknown.
+//
+// privateKey signs the request object with alg, e.g. `RS256` or `ES256`, it
+// can be any crypto.Signer, e.g. a key in KMS, see NewJWTSigner.
+// keyID is set as the `kid` header if not empty. The returned JWT can be sent
+// as the `request` parameter, e.g. by appauth.UseAuthCodeOptions with
+// `oauth2.SetAuthURLParam("request", jwt)`.
+func BuildRequestJWT(endpoint *Endpoint, cfg *oauth2.Config, privateKey crypto.Signer, keyID string, alg string, params neturl.Values) (string, error) {
+	if endpoint == nil {
+		return "", errors.New("endpoint must not be nil")
+	}
+	if cfg == nil {
+		return "", errors.New("oauth2 config must not be nil")
+	}
+	if privateKey == nil {
+		return "", errors.New("private key must not be nil")
+	}
+	audience := endpoint.Issuer
+	if audience == "" {
+		audience = endpoint.AuthURL
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := map[string]interface{}{}
+	for key, values := range params {
+		if len(values) > 0 {
+			claims[key] = values[0]
+		}
+	}
+	claims["iss"] = cfg.ClientID
+	claims["aud"] = audience
+	claims["client_id"] = cfg.ClientID
+	claims["response_type"] = "code"
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(requestObjectLifetime).Unix()
+	claims["jti"] = base64.RawURLEncoding.EncodeToString(jti)
+	if cfg.RedirectURL != "" {
+		claims["redirect_uri"] = cfg.RedirectURL
+	}
+	if len(cfg.Scopes) > 0 {
+		claims["scope"] = strings.Join(cfg.Scopes, " ")
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signer, err := NewJWTSigner(privateKey, keyID, jose.SignatureAlgorithm(alg), "oauth-authz-req+jwt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign request object: %w", err)
+	}
+	return signed.CompactSerialize()
+}
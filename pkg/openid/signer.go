@@ -0,0 +1,101 @@
+package openid
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// NewJWTSigner creates a JWS signer signs with key using alg, with the `typ`
+// header set to typ and the `kid` header set to keyID if not empty.
+//
+// go-jose only accepts concrete private keys, hence other crypto.Signer
+// implementations, e.g. keys in KMS or HSM, are wrapped as jose.OpaqueSigner.
+func NewJWTSigner(key crypto.Signer, keyID string, alg jose.SignatureAlgorithm, typ jose.ContentType) (jose.Signer, error) {
+	var signingKey interface{}
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		signingKey = jose.JSONWebKey{Key: key, KeyID: keyID}
+	default:
+		signingKey = &opaqueSigner{key: key, keyID: keyID, alg: alg}
+	}
+	return jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: signingKey},
+		(&jose.SignerOptions{}).WithType(typ),
+	)
+}
+
+// opaqueSigner implements jose.OpaqueSigner by crypto.Signer.
+type opaqueSigner struct {
+	key   crypto.Signer
+	keyID string
+	alg   jose.SignatureAlgorithm
+}
+
+var _ jose.OpaqueSigner = &opaqueSigner{}
+
+func (s *opaqueSigner) Public() *jose.JSONWebKey {
+	return &jose.JSONWebKey{Key: s.key.Public(), KeyID: s.keyID, Algorithm: string(s.alg)}
+}
+
+func (s *opaqueSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{s.alg}
+}
+
+func (s *opaqueSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	var hash crypto.Hash
+	switch alg {
+	case jose.RS256, jose.PS256, jose.ES256:
+		hash = crypto.SHA256
+	case jose.RS384, jose.PS384, jose.ES384:
+		hash = crypto.SHA384
+	case jose.RS512, jose.PS512, jose.ES512:
+		hash = crypto.SHA512
+	case jose.EdDSA:
+		// ed25519 signs the message itself
+		return s.key.Sign(rand.Reader, payload, crypto.Hash(0))
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %s", alg)
+	}
+	h := hash.New()
+	h.Write(payload)
+	digest := h.Sum(nil)
+	switch alg {
+	case jose.PS256, jose.PS384, jose.PS512:
+		return s.key.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash})
+	case jose.ES256, jose.ES384, jose.ES512:
+		der, err := s.key.Sign(rand.Reader, digest, hash)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := s.key.Public().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an ECDSA key", alg)
+		}
+		return ecdsaJWSSignature(der, pub)
+	}
+	return s.key.Sign(rand.Reader, digest, hash)
+}
+
+// ecdsaJWSSignature converts the ASN.1 DER signature returned by crypto.Signer
+// into the fixed size `R || S` form used by JWS, see rfc7518 section 3.4.
+func ecdsaJWSSignature(der []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("malformed ECDSA signature: %w", err)
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
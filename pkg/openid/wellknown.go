@@ -0,0 +1,65 @@
+package openid
+
+import (
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+const (
+	openIDConfigurationPath      = "/.well-known/openid-configuration"
+	oauthAuthorizationServerPath = "/.well-known/oauth-authorization-server"
+)
+
+// WellKnownURL returns the OpenID Connect discovery URL of issuerURI, which is
+// the same URL requested by Discover. According to OpenID Connect Discovery 1.0
+// section 4, the well-known path is appended to the issuer, including its path
+// component, e.g. `https://example.com/oauth2/v1` becomes
+// `https://example.com/oauth2/v1/.well-known/openid-configuration`.
+// A trailing slash of the issuer is removed before appending.
+//
+// The issuer must be an absolute http(s) URL without query or fragment.
+func WellKnownURL(issuerURI string) (string, error) {
+	u, err := parseIssuer(issuerURI)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + openIDConfigurationPath
+	return u.String(), nil
+}
+
+// OAuthWellKnownURL returns the OAuth 2.0 authorization server metadata URL
+// of issuerURI. Unlike WellKnownURL, rfc8414 section 3.1 inserts the well-known
+// path between the host and the path component of the issuer, e.g.
+// `https://example.com/oauth2/v1` becomes
+// `https://example.com/.well-known/oauth-authorization-server/oauth2/v1`.
+func OAuthWellKnownURL(issuerURI string) (string, error) {
+	u, err := parseIssuer(issuerURI)
+	if err != nil {
+		return "", err
+	}
+	u.Path = oauthAuthorizationServerPath + strings.TrimSuffix(u.Path, "/")
+	return u.String(), nil
+}
+
+// parseIssuer parses and validates issuerURI.
+func parseIssuer(issuerURI string) (*neturl.URL, error) {
+	if issuerURI == "" {
+		return nil, errors.New("issuer must not be empty")
+	}
+	u, err := neturl.Parse(issuerURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid issuer %q: scheme must be https or http", issuerURI)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid issuer %q: host must not be empty", issuerURI)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid issuer %q: query and fragment are not allowed", issuerURI)
+	}
+	return u, nil
+}
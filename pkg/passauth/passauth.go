@@ -0,0 +1,136 @@
+// Package passauth implements the OAuth2 resource owner password
+// credentials grant described in rfc6749 section 4.3. It's only
+// for environments where no other grant is feasible, since the
+// client handles the user password directly.
+package passauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/tiewei/otoken/pkg/types"
+)
+
+// GrantType is the grant type used by password credentials requests.
+const GrantType = "password"
+
+// Option configures optional field for TokenSource,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type Option interface {
+	apply(*TokenSource)
+}
+
+type option struct {
+	applyFunc func(*TokenSource)
+}
+
+func (o option) apply(s *TokenSource) {
+	o.applyFunc(s)
+}
+
+// UseHTTPClient sets http client used to make http requests.
+func UseHTTPClient(c *http.Client) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.client = c
+	}}
+}
+
+// Timeout sets additional timeout for the token request.
+func Timeout(t time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.timeout = t
+	}}
+}
+
+// UseCredentialsFunc sets the func to get username and password when
+// they're not given to NewTokenSource, e.g. prompting the user. It's
+// only called when a new token is requested, hence a cached token
+// doesn't require user input.
+func UseCredentialsFunc(credentials func() (username string, password string, err error)) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.credentials = credentials
+	}}
+}
+
+// TokenSource implements oauth2.TokenSource interface
+// to provide token via resource owner password credentials
+// grant described in rfc6749 section 4.3.
+type TokenSource struct {
+	tokenEndpoint string
+	clientID      string
+	clientSecret  string
+	username      string
+	password      string
+	scopes        []string
+
+	credentials func() (string, string, error)
+	client      *http.Client
+	timeout     time.Duration
+}
+
+var _ oauth2.TokenSource = &TokenSource{}
+
+// NewTokenSource creates a new password credentials token source,
+// the client is authenticated by clientSecret if it's not empty.
+// The username and password can be empty if UseCredentialsFunc is set.
+// It by default uses `http.DefaultClient` as http client,
+// to change it, set Options when creating the instance.
+func NewTokenSource(tokenEndpoint string, clientID string, clientSecret string, username string, password string, scopes []string, opts ...Option) *TokenSource {
+	s := &TokenSource{
+		tokenEndpoint: tokenEndpoint,
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		username:      username,
+		password:      password,
+		scopes:        scopes,
+
+		client: http.DefaultClient,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(s)
+		}
+	}
+	return s
+}
+
+// Token requests a new oauth2.Token by the user password, the credentials
+// func set by UseCredentialsFunc is called if username or password is empty.
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	username, password := s.username, s.password
+	if (username == "" || password == "") && s.credentials != nil {
+		var err error
+		if username, password, err = s.credentials(); err != nil {
+			return nil, err
+		}
+	}
+	if username == "" || password == "" {
+		return nil, errors.New("username and password are required")
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, s.client)
+	if s.timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
+		defer cancelFunc()
+	}
+	cfg := &oauth2.Config{
+		ClientID:     s.clientID,
+		ClientSecret: s.clientSecret,
+		Endpoint: oauth2.Endpoint{
+			TokenURL:  s.tokenEndpoint,
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: s.scopes,
+	}
+	if s.clientSecret == "" {
+		// public client only identifies itself by client_id
+		cfg.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+	token, err := cfg.PasswordCredentialsToken(ctx, username, password)
+	return token, types.TokenError(err)
+}
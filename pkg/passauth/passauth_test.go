@@ -0,0 +1,89 @@
+package passauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tiewei/otoken/pkg/internal/oauthtest"
+)
+
+// tokenEndpoint grants the password request of alice:pass, the client
+// must authenticate by client:secret if wantClientAuth, otherwise it
+// sends only client_id as a public client.
+func tokenEndpoint(t *testing.T, wantClientAuth bool) string {
+	return oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		if got := r.PostForm.Get("grant_type"); got != GrantType {
+			t.Errorf("grant_type = %q, want %q", got, GrantType)
+		}
+		id, secret, basic := oauthtest.ClientCredentials(r)
+		if wantClientAuth && (!basic || id != "client" || secret != "secret") {
+			t.Errorf("basic auth = %q:%q, want client:secret", id, secret)
+		}
+		if !wantClientAuth && (basic || id != "client" || secret != "") {
+			t.Errorf("public client must send client_id only, got basic auth %t, client_id %q", basic, id)
+		}
+		if r.PostForm.Get("username") != "alice" || r.PostForm.Get("password") != "pass" {
+			return oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "bad credentials"})
+		}
+		return oauthtest.JSON(http.StatusOK, map[string]interface{}{
+			"access_token": "at", "refresh_token": "rt", "token_type": "Bearer", "expires_in": 60, "id_token": "id",
+		})
+	}).URL
+}
+
+func TestToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientSecret string
+	}{
+		{name: "confidential client", clientSecret: "secret"},
+		{name: "public client"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := NewTokenSource(tokenEndpoint(t, tt.clientSecret != ""), "client", tt.clientSecret, "alice", "pass", []string{"openid"}).Token()
+			if err != nil {
+				t.Fatalf("Token() returned error: %s", err)
+			}
+			if token.AccessToken != "at" || token.RefreshToken != "rt" || token.Extra("id_token") != "id" {
+				t.Errorf("Token() = %+v, want access token at, refresh token rt and id token id", token)
+			}
+		})
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	_, err := NewTokenSource(tokenEndpoint(t, true), "client", "secret", "alice", "wrong", nil).Token()
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant, bad credentials") {
+		t.Fatalf("Token() error = %v, want invalid_grant", err)
+	}
+}
+
+func TestTokenCredentialsFunc(t *testing.T) {
+	tokenURL := tokenEndpoint(t, true)
+	calls := 0
+	credentials := UseCredentialsFunc(func() (string, string, error) {
+		calls++
+		return "alice", "pass", nil
+	})
+	src := NewTokenSource(tokenURL, "client", "secret", "", "", nil, credentials)
+	if calls != 0 {
+		t.Fatalf("credentials func is called %d times before Token()", calls)
+	}
+	if _, err := src.Token(); err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if calls != 1 {
+		t.Errorf("credentials func is called %d times, want 1", calls)
+	}
+
+	errPrompt := errors.New("no terminal")
+	failing := UseCredentialsFunc(func() (string, string, error) {
+		return "", "", errPrompt
+	})
+	if _, err := NewTokenSource(tokenURL, "client", "secret", "", "", nil, failing).Token(); !errors.Is(err, errPrompt) {
+		t.Errorf("Token() error = %v, want %v", err, errPrompt)
+	}
+}
@@ -3,15 +3,24 @@ package refresher
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/tiewei/otoken/pkg/openid"
 	"golang.org/x/oauth2"
 )
 
+// ErrAudienceMismatch is returned when the refreshed access token
+// is not issued to the expected audience.
+var ErrAudienceMismatch = errors.New("audience of access token mismatch")
+
 type TokenRefresher struct {
-	cfg           *oauth2.Config
-	refreshClient *http.Client
+	cfg              *oauth2.Config
+	refreshClient    *http.Client
+	expectedAudience string
+	rotationHook     func(oldRefreshToken, newRefreshToken string)
 }
 
 // Option configures optional field for TokenRefresher,
@@ -36,6 +45,24 @@ func UseHTTPClient(c *http.Client) Option {
 	}}
 }
 
+// WithAudienceValidation checks the `aud` claim of the refreshed access
+// token contains expectedAudience, Refresh returns ErrAudienceMismatch if not.
+// The access token must be a JWT, its signature is not verified.
+func WithAudienceValidation(expectedAudience string) Option {
+	return &option{applyFunc: func(t *TokenRefresher) {
+		t.expectedAudience = expectedAudience
+	}}
+}
+
+// WithRotationHook calls fn in a new goroutine whenever a refresh returns
+// a new refresh token, e.g. to record the rotation event. A panic of fn is
+// recovered and logged.
+func WithRotationHook(fn func(oldRefreshToken, newRefreshToken string)) Option {
+	return &option{applyFunc: func(t *TokenRefresher) {
+		t.rotationHook = fn
+	}}
+}
+
 // New creates a new refresher TokenSource
 func New(tokenURL string, clientID string, opts ...Option) *TokenRefresher {
 	ts := &TokenRefresher{
@@ -55,6 +82,15 @@ func New(tokenURL string, clientID string, opts ...Option) *TokenRefresher {
 	return ts
 }
 
+// NewWithEndpoint creates a new refresher TokenSource with the full endpoint,
+// the `AuthStyle` of endpoint is preserved.
+func NewWithEndpoint(endpoint oauth2.Endpoint, clientID string, clientSecret string, opts ...Option) *TokenRefresher {
+	ts := New(endpoint.TokenURL, clientID, opts...)
+	ts.cfg.Endpoint = endpoint
+	ts.cfg.ClientSecret = clientSecret
+	return ts
+}
+
 func (r *TokenRefresher) Refresh(refreshToken string) (*oauth2.Token, error) {
 	if refreshToken == "" {
 		return nil, errors.New("no refresh token provided")
@@ -67,7 +103,43 @@ func (r *TokenRefresher) Refresh(refreshToken string) (*oauth2.Token, error) {
 	if r.refreshClient != nil {
 		ctx = context.WithValue(ctx, oauth2.HTTPClient, r.refreshClient)
 	}
-	return r.cfg.TokenSource(ctx, currentToken).Token()
+	token, err := r.cfg.TokenSource(ctx, currentToken).Token()
+	if err != nil {
+		return nil, err
+	}
+	if r.expectedAudience != "" {
+		if err := validateAudience(token.AccessToken, r.expectedAudience); err != nil {
+			return nil, err
+		}
+	}
+	if r.rotationHook != nil && token.RefreshToken != "" && token.RefreshToken != refreshToken {
+		go notifyRotation(r.rotationHook, refreshToken, token.RefreshToken)
+	}
+	return token, nil
+}
+
+// notifyRotation calls hook and recovers its panic.
+func notifyRotation(hook func(oldRefreshToken, newRefreshToken string), oldRefreshToken string, newRefreshToken string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("refresh token rotation hook panicked: %v", r)
+		}
+	}()
+	hook(oldRefreshToken, newRefreshToken)
+}
+
+// validateAudience checks the `aud` claim of accessToken.
+func validateAudience(accessToken string, expectedAudience string) error {
+	claims := struct {
+		Audience openid.Audience `json:"aud"`
+	}{}
+	if err := openid.ParseTokenClaims(accessToken, &claims); err != nil {
+		return fmt.Errorf("failed to parse access token: %w", err)
+	}
+	if !claims.Audience.Contains(expectedAudience) {
+		return fmt.Errorf("%w: expected %s, got %v", ErrAudienceMismatch, expectedAudience, []string(claims.Audience))
+	}
+	return nil
 }
 
 type TokenSource struct {
@@ -0,0 +1,201 @@
+// Package tokenexchange implements the OAuth2 token exchange
+// grant described in rfc8693. It allows a client to swap a
+// subject token (e.g. an access token from another tenant or
+// a SAML assertion) for a new token issued by the authorization server.
+package tokenexchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// GrantType is the grant type used by token exchange requests.
+const GrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// Token type identifiers defined in
+// https://datatracker.ietf.org/doc/html/rfc8693#section-3
+const (
+	AccessTokenType  = "urn:ietf:params:oauth:token-type:access_token"
+	RefreshTokenType = "urn:ietf:params:oauth:token-type:refresh_token"
+	IDTokenType      = "urn:ietf:params:oauth:token-type:id_token"
+	SAML1TokenType   = "urn:ietf:params:oauth:token-type:saml1"
+	SAML2TokenType   = "urn:ietf:params:oauth:token-type:saml2"
+	JWTTokenType     = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// Option configures optional field for TokenSource,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type Option interface {
+	apply(*TokenSource)
+}
+
+type option struct {
+	applyFunc func(*TokenSource)
+}
+
+func (o option) apply(s *TokenSource) {
+	o.applyFunc(s)
+}
+
+// UseHTTPClient sets http client used to make http requests.
+func UseHTTPClient(c *http.Client) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.client = c
+	}}
+}
+
+// Timeout sets additional timeout for the token exchange request.
+func Timeout(t time.Duration) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.timeout = t
+	}}
+}
+
+// UseClientSecret sets client secret to authenticate the client
+// with the token endpoint.
+func UseClientSecret(secret string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.clientSecret = secret
+	}}
+}
+
+// UseRequestedTokenType sets the type of the token being requested,
+// if empty, the authorization server decides the issued token type.
+func UseRequestedTokenType(tokenType string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.requestedTokenType = tokenType
+	}}
+}
+
+// UseScopes sets the scopes of the requested token.
+func UseScopes(scopes []string) Option {
+	return &option{applyFunc: func(s *TokenSource) {
+		s.scopes = scopes
+	}}
+}
+
+// TokenSource implements oauth2.TokenSource interface
+// to provide token via token exchange grant described in rfc8693.
+type TokenSource struct {
+	tokenEndpoint    string
+	clientID         string
+	subjectToken     string
+	subjectTokenType string
+
+	clientSecret       string
+	requestedTokenType string
+	scopes             []string
+	client             *http.Client
+	timeout            time.Duration
+}
+
+var _ oauth2.TokenSource = &TokenSource{}
+
+type tokenRaw struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	RefreshToken    string `json:"refresh_token"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope"`
+}
+
+type tokenErrResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// New creates a new token exchange token source.
+// It by default uses `http.DefaultClient` as http client,
+// to change it, set Options when creating the instance.
+func New(tokenEndpoint string, clientID string, subjectToken string, subjectTokenType string, opts ...Option) *TokenSource {
+	s := &TokenSource{
+		tokenEndpoint:    tokenEndpoint,
+		clientID:         clientID,
+		subjectToken:     subjectToken,
+		subjectTokenType: subjectTokenType,
+
+		client: http.DefaultClient,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(s)
+		}
+	}
+	return s
+}
+
+// Token exchanges the subject token for a new oauth2.Token.
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, s.timeout)
+		defer cancelFunc()
+	}
+	values := url.Values{
+		"grant_type":         {GrantType},
+		"client_id":          {s.clientID},
+		"subject_token":      {s.subjectToken},
+		"subject_token_type": {s.subjectTokenType},
+	}
+	if s.requestedTokenType != "" {
+		values.Set("requested_token_type", s.requestedTokenType)
+	}
+	if len(s.scopes) > 0 {
+		values.Set("scope", strings.Join(s.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if s.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		errResp := &tokenErrResponse{}
+		if err := json.Unmarshal(body, errResp); err == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("failed to exchange token: %s, %s", errResp.Error, errResp.ErrorDescription)
+		}
+		return nil, fmt.Errorf("failed to exchange token: response code %d, %s", resp.StatusCode, string(body))
+	}
+
+	data := &tokenRaw{}
+	if err := json.Unmarshal(body, data); err != nil {
+		return nil, err
+	}
+	if data.AccessToken == "" {
+		return nil, fmt.Errorf("%s is not a valid token exchange response", string(body))
+	}
+	token := &oauth2.Token{
+		AccessToken:  data.AccessToken,
+		RefreshToken: data.RefreshToken,
+		TokenType:    data.TokenType,
+	}
+	if data.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+	}
+	return token.WithExtra(map[string]interface{}{
+		"issued_token_type": data.IssuedTokenType,
+		"scope":             data.Scope,
+	}), nil
+}
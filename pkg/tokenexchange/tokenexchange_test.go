@@ -0,0 +1,97 @@
+package tokenexchange
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tiewei/otoken/pkg/internal/oauthtest"
+)
+
+func TestToken(t *testing.T) {
+	tokenURL := oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		want := map[string]string{
+			"grant_type":           GrantType,
+			"subject_token":        "subject",
+			"subject_token_type":   AccessTokenType,
+			"requested_token_type": JWTTokenType,
+			"scope":                "read write",
+		}
+		for param, value := range want {
+			if got := r.PostForm.Get(param); got != value {
+				t.Errorf("%s = %q, want %q", param, got, value)
+			}
+		}
+		if id, secret, basic := oauthtest.ClientCredentials(r); !basic || id != "client" || secret != "secret" {
+			t.Errorf("basic auth = %q:%q, want client:secret", id, secret)
+		}
+		return oauthtest.JSON(http.StatusOK, map[string]interface{}{
+			"access_token": "exchanged", "issued_token_type": JWTTokenType, "token_type": "N_A", "expires_in": 60, "scope": "read",
+		})
+	}).URL
+
+	token, err := New(tokenURL, "client", "subject", AccessTokenType,
+		UseClientSecret("secret"), UseRequestedTokenType(JWTTokenType), UseScopes([]string{"read", "write"})).Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "exchanged" || token.TokenType != "N_A" || token.Expiry.IsZero() {
+		t.Errorf("Token() = %+v, want access token exchanged of type N_A with expiry", token)
+	}
+	if got := token.Extra("issued_token_type"); got != JWTTokenType {
+		t.Errorf("issued_token_type = %v, want %s", got, JWTTokenType)
+	}
+	if got := token.Extra("scope"); got != "read" {
+		t.Errorf("scope = %v, want read", got)
+	}
+}
+
+func TestTokenPublicClient(t *testing.T) {
+	tokenURL := oauthtest.NewServer(t, func(r *http.Request) oauthtest.Response {
+		if id, _, basic := oauthtest.ClientCredentials(r); basic || id != "client" {
+			t.Errorf("public client must send client_id only, got basic auth %t, client_id %q", basic, id)
+		}
+		for _, param := range []string{"requested_token_type", "scope"} {
+			if _, ok := r.PostForm[param]; ok {
+				t.Errorf("%s is sent without being set", param)
+			}
+		}
+		return oauthtest.JSON(http.StatusOK, map[string]interface{}{"access_token": "exchanged", "token_type": "Bearer"})
+	}).URL
+	if _, err := New(tokenURL, "client", "subject", IDTokenType).Token(); err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    oauthtest.Response
+		wantErr string
+	}{
+		{
+			name:    "json error",
+			resp:    oauthtest.JSON(http.StatusBadRequest, map[string]string{"error": "invalid_target", "error_description": "unknown audience"}),
+			wantErr: "failed to exchange token: invalid_target, unknown audience",
+		},
+		{
+			name:    "non-json error",
+			resp:    oauthtest.Text(http.StatusBadGateway, "upstream unavailable"),
+			wantErr: "failed to exchange token: response code 502, upstream unavailable",
+		},
+		{
+			name:    "no access token",
+			resp:    oauthtest.JSON(http.StatusOK, map[string]string{"token_type": "Bearer"}),
+			wantErr: "is not a valid token exchange response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenURL := oauthtest.NewServer(t, func(*http.Request) oauthtest.Response { return tt.resp }).URL
+			_, err := New(tokenURL, "client", "subject", AccessTokenType).Token()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Token() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
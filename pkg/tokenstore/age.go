@@ -0,0 +1,78 @@
+package tokenstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// IssuedAtReader is a Store records when the cached token is issued.
+type IssuedAtReader interface {
+	IssuedAt() (time.Time, error)
+}
+
+var _ IssuedAtReader = &FileStore{}
+
+// IssuedAt returns when the token in file is saved, it's zero if the
+// token is saved in legacy format or by a custom serializer.
+func (f *FileStore) IssuedAt() (time.Time, error) {
+	f.mu.Lock()
+	path := f.tokenPath()
+	f.mu.Unlock()
+	if f.unmarshal != nil {
+		return time.Time{}, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	stored := &storedToken{}
+	if err := json.Unmarshal(raw, stored); err != nil {
+		return time.Time{}, err
+	}
+	return stored.IssuedAt, nil
+}
+
+// TokenAge returns how long the cached token has been issued, it reads the
+// token from Store without refreshing it. The age is from the issue time
+// recorded by the Store if it's an IssuedAtReader, otherwise approximated
+// from `Expiry` and the `expires_in` field of the token.
+func (c *CachedTokenSource) TokenAge() (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	token, err := c.Store.Token()
+	if err != nil {
+		return 0, err
+	}
+	if token == nil {
+		return 0, errors.New("no cached token found")
+	}
+	if r, ok := c.Store.(IssuedAtReader); ok {
+		issuedAt, err := r.IssuedAt()
+		if err == nil && !issuedAt.IsZero() {
+			return time.Since(issuedAt), nil
+		}
+	}
+	if expiresIn := expiresInSeconds(token.Extra("expires_in")); expiresIn > 0 && !token.Expiry.IsZero() {
+		issuedAt := token.Expiry.Add(-time.Duration(expiresIn) * time.Second)
+		return time.Since(issuedAt), nil
+	}
+	return 0, errors.New("token age is unknown")
+}
+
+// expiresInSeconds converts the `expires_in` field to seconds.
+func expiresInSeconds(v interface{}) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+// Package awsstore implements `tokenstore.Store` backed by AWS Secrets Manager,
+// the token is saved as the secret string in the tokenstore file format.
+package awsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"golang.org/x/oauth2"
+
+	"github.com/tiewei/otoken/pkg/tokenstore"
+)
+
+// SecretNameEnv is the environment variable of the secret name used by NewFromEnv.
+const SecretNameEnv = "OTOKEN_AWS_SECRET_NAME"
+
+// secretsAPI is the subset of secretsmanager.Client used by the store.
+type secretsAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+}
+
+// Store implements `tokenstore.Store` interface saves token
+// as the secret string of a secret in AWS Secrets Manager.
+type Store struct {
+	client     secretsAPI
+	secretName string
+}
+
+var _ tokenstore.Store = &Store{}
+
+// New creates a Store saves token in secretName by client.
+func New(client *secretsmanager.Client, secretName string) tokenstore.Store {
+	return &Store{
+		client:     client,
+		secretName: secretName,
+	}
+}
+
+// NewFromEnv creates a Store with the client configured by the region in
+// `AWS_REGION` and the default credential chain of the AWS SDK, the secret
+// name is read from `OTOKEN_AWS_SECRET_NAME`.
+func NewFromEnv() (tokenstore.Store, error) {
+	secretName := os.Getenv(SecretNameEnv)
+	if secretName == "" {
+		return nil, fmt.Errorf("%s is required", SecretNameEnv)
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("AWS_REGION is required")
+	}
+	return New(secretsmanager.NewFromConfig(cfg), secretName), nil
+}
+
+func (s *Store) Token() (*oauth2.Token, error) {
+	out, err := s.client.GetSecretValue(context.Background(), &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.secretName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", s.secretName, err)
+	}
+	token, err := tokenstore.DecodeToken([]byte(aws.ToString(out.SecretString)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode token in secret %s: %w", s.secretName, err)
+	}
+	return token, nil
+}
+
+// Save puts the token as a new version of the secret,
+// the secret is created if it doesn't exist.
+func (s *Store) Save(token *oauth2.Token) error {
+	raw, err := tokenstore.EncodeToken(token)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	_, err = s.client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(s.secretName),
+		SecretString: aws.String(string(raw)),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		_, err = s.client.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+			Name:         aws.String(s.secretName),
+			SecretString: aws.String(string(raw)),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save secret %s: %w", s.secretName, err)
+	}
+	return nil
+}
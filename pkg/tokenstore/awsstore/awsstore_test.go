@@ -0,0 +1,89 @@
+package awsstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"golang.org/x/oauth2"
+)
+
+// fakeSecrets is a secretsAPI keeps secrets in memory.
+type fakeSecrets struct {
+	secrets map[string]string
+	created []string
+	putErr  error
+}
+
+var _ secretsAPI = &fakeSecrets{}
+
+func (f *fakeSecrets) GetSecretValue(_ context.Context, in *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	value, ok := f.secrets[aws.ToString(in.SecretId)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("secret not found")}
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func (f *fakeSecrets) PutSecretValue(_ context.Context, in *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	if _, ok := f.secrets[aws.ToString(in.SecretId)]; !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("secret not found")}
+	}
+	f.secrets[aws.ToString(in.SecretId)] = aws.ToString(in.SecretString)
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (f *fakeSecrets) CreateSecret(_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	f.created = append(f.created, aws.ToString(in.Name))
+	f.secrets[aws.ToString(in.Name)] = aws.ToString(in.SecretString)
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func TestSaveCreatesMissingSecret(t *testing.T) {
+	fake := &fakeSecrets{secrets: map[string]string{}}
+	store := &Store{client: fake, secretName: "otoken/client"}
+	if _, err := store.Token(); err == nil {
+		t.Fatal("Token() returned no error for missing secret")
+	}
+
+	first := &oauth2.Token{AccessToken: "first", Expiry: time.Now().Add(time.Hour).Round(time.Second)}
+	if err := store.Save(first); err != nil {
+		t.Fatalf("Save() returned error: %s", err)
+	}
+	if len(fake.created) != 1 || fake.created[0] != "otoken/client" {
+		t.Errorf("created secrets %v, want otoken/client", fake.created)
+	}
+	second := &oauth2.Token{AccessToken: "second", Expiry: time.Now().Add(time.Hour).Round(time.Second)}
+	if err := store.Save(second); err != nil {
+		t.Fatalf("Save() returned error: %s", err)
+	}
+	if len(fake.created) != 1 {
+		t.Errorf("created secrets %v, want the existing secret updated", fake.created)
+	}
+	token, err := store.Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "second" || !token.Expiry.Equal(second.Expiry) {
+		t.Errorf("Token() = %+v, want the saved token %+v", token, second)
+	}
+}
+
+func TestSaveError(t *testing.T) {
+	errDenied := errors.New("access denied")
+	fake := &fakeSecrets{secrets: map[string]string{}, putErr: errDenied}
+	store := &Store{client: fake, secretName: "otoken/client"}
+	if err := store.Save(&oauth2.Token{AccessToken: "at"}); !errors.Is(err, errDenied) {
+		t.Errorf("Save() error = %v, want %v", err, errDenied)
+	}
+	if len(fake.created) != 0 {
+		t.Errorf("created secrets %v on a non not-found error", fake.created)
+	}
+}
@@ -0,0 +1,37 @@
+package tokenstore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+const backupSuffix = ".bak"
+
+// Backup copies the current token file to `<path>.bak` with the same
+// permissions, it's a no-op if the token file doesn't exist.
+func (f *FileStore) Backup() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return backupFile(f.tokenPath())
+}
+
+func backupFile(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	backup := path + backupSuffix
+	if err := os.WriteFile(backup, raw, info.Mode().Perm()); err != nil {
+		return err
+	}
+	// WriteFile doesn't change the permissions of an existing file
+	return os.Chmod(backup, info.Mode().Perm())
+}
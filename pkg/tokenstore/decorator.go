@@ -0,0 +1,139 @@
+package tokenstore
+
+import (
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// StorageDecorator wraps a Store to add cross-cutting concerns,
+// like http.Handler middlewares.
+type StorageDecorator func(Store) Store
+
+// Decorate wraps store by decorators, the first decorator is the outermost,
+// e.g. Decorate(store, a, b) is the same as a(b(store)).
+func Decorate(store Store, decorators ...StorageDecorator) Store {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		if decorators[i] != nil {
+			store = decorators[i](store)
+		}
+	}
+	return store
+}
+
+// storeFuncs implements Store by functions, and forwards the optional
+// interfaces Keyer, IssuedAtReader and Backup to inner, hence they're
+// not hidden by decorators. They return zero values if inner doesn't
+// implement them, which makes the callers fall back to their default,
+// e.g. TokenAge approximates the age from the token expiry.
+type storeFuncs struct {
+	inner Store
+	token func() (*oauth2.Token, error)
+	save  func(*oauth2.Token) error
+}
+
+var (
+	_ Keyer          = &storeFuncs{}
+	_ IssuedAtReader = &storeFuncs{}
+)
+
+func (s *storeFuncs) Token() (*oauth2.Token, error) {
+	return s.token()
+}
+
+func (s *storeFuncs) Save(token *oauth2.Token) error {
+	return s.save(token)
+}
+
+func (s *storeFuncs) Key() string {
+	if k, ok := s.inner.(Keyer); ok {
+		return k.Key()
+	}
+	return ""
+}
+
+func (s *storeFuncs) IssuedAt() (time.Time, error) {
+	if r, ok := s.inner.(IssuedAtReader); ok {
+		return r.IssuedAt()
+	}
+	return time.Time{}, nil
+}
+
+// Backup backs up the token of the inner store, e.g. FileStore.Backup,
+// it's a no-op if the inner store doesn't support backup.
+func (s *storeFuncs) Backup() error {
+	if b, ok := s.inner.(interface{ Backup() error }); ok {
+		return b.Backup()
+	}
+	return nil
+}
+
+// WithLogging returns a Store logs every operation of store by logf,
+// the token itself is never logged.
+func WithLogging(store Store, logf func(string, ...any)) Store {
+	return &storeFuncs{
+		inner: store,
+		token: func() (*oauth2.Token, error) {
+			token, err := store.Token()
+			if err != nil {
+				logf("failed to read token from store: %s", err)
+			} else if token == nil {
+				logf("no token in store")
+			} else {
+				logf("read token from store, expiry: %s", token.Expiry)
+			}
+			return token, err
+		},
+		save: func(token *oauth2.Token) error {
+			err := store.Save(token)
+			if err != nil {
+				logf("failed to save token to store: %s", err)
+			} else if token == nil {
+				logf("saved empty token to store")
+			} else {
+				logf("saved token to store, expiry: %s", token.Expiry)
+			}
+			return err
+		},
+	}
+}
+
+// Metrics records operations of a Store, op is either `token` or `save`.
+// It can be implemented by e.g. a prometheus histogram labelled by op
+// and whether err is nil.
+type Metrics interface {
+	Observe(op string, duration time.Duration, err error)
+}
+
+// WithMetrics returns a Store records every operation of store to metrics.
+func WithMetrics(store Store, metrics Metrics) Store {
+	return &storeFuncs{
+		inner: store,
+		token: func() (*oauth2.Token, error) {
+			start := time.Now()
+			token, err := store.Token()
+			metrics.Observe("token", time.Since(start), err)
+			return token, err
+		},
+		save: func(token *oauth2.Token) error {
+			start := time.Now()
+			err := store.Save(token)
+			metrics.Observe("save", time.Since(start), err)
+			return err
+		},
+	}
+}
+
+// Logging is the StorageDecorator of WithLogging.
+func Logging(logf func(string, ...any)) StorageDecorator {
+	return func(store Store) Store {
+		return WithLogging(store, logf)
+	}
+}
+
+// Metered is the StorageDecorator of WithMetrics.
+func Metered(metrics Metrics) StorageDecorator {
+	return func(store Store) Store {
+		return WithMetrics(store, metrics)
+	}
+}
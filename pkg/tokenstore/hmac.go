@@ -0,0 +1,80 @@
+package tokenstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const hmacField = "hmac"
+
+// ErrTokenTampered is returned when the HMAC of the token file doesn't match.
+var ErrTokenTampered = errors.New("token file is tampered")
+
+// WithHMACSigning signs the token file with HMAC-SHA256 using key in Save,
+// the signature is stored in the `hmac` field of the token JSON. Token
+// returns ErrTokenTampered if the signature is missing or doesn't match,
+// hence tokens saved without signing can't be read. The serializer must
+// produce a JSON object. It returns the FileStore itself.
+func (f *FileStore) WithHMACSigning(key []byte) *FileStore {
+	f.hmacKey = key
+	return f
+}
+
+// signToken adds the `hmac` field to the token JSON raw.
+func signToken(raw []byte, key []byte) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+	delete(fields, hmacField)
+	mac, err := tokenMAC(fields, key)
+	if err != nil {
+		return nil, err
+	}
+	fields[hmacField], err = json.Marshal(hex.EncodeToString(mac))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// verifyToken checks the `hmac` field of the token JSON raw.
+func verifyToken(raw []byte, key []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	var signature string
+	if err := json.Unmarshal(fields[hmacField], &signature); err != nil {
+		return ErrTokenTampered
+	}
+	delete(fields, hmacField)
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrTokenTampered
+	}
+	mac, err := tokenMAC(fields, key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		return ErrTokenTampered
+	}
+	return nil
+}
+
+// tokenMAC computes HMAC-SHA256 over fields, which are marshaled with
+// sorted keys hence the result doesn't depend on the field order in file.
+func tokenMAC(fields map[string]json.RawMessage, key []byte) ([]byte, error) {
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(canonical)
+	return h.Sum(nil), nil
+}
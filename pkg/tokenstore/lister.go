@@ -0,0 +1,87 @@
+package tokenstore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// CacheEntry is a token cached in store.
+type CacheEntry struct {
+	Key   string
+	Token *oauth2.Token
+	Valid bool
+}
+
+// Lister is a Store can enumerate the cached tokens.
+type Lister interface {
+	List() ([]CacheEntry, error)
+}
+
+var _ Lister = &FileStore{}
+
+// List lists tokens saved in the same directory as the FileStore's path,
+// the file name is used as the entry key. Files can't be parsed as token
+// are skipped.
+func (f *FileStore) List() ([]CacheEntry, error) {
+	return listDir(filepath.Dir(f.Path), f.decode)
+}
+
+// dirLister lists tokens saved in a directory.
+type dirLister struct {
+	dir string
+}
+
+// ListDir returns a Lister lists tokens saved in dir by FileStore with the
+// default serializer, the file name is used as the entry key.
+func ListDir(dir string) Lister {
+	return &dirLister{dir: dir}
+}
+
+func (d *dirLister) List() ([]CacheEntry, error) {
+	return listDir(d.dir, DecodeToken)
+}
+
+// listDir lists tokens in dir decoded by decode, files can't be
+// decoded as token are skipped.
+func listDir(dir string, decode func([]byte) (*oauth2.Token, error)) ([]CacheEntry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := []CacheEntry{}
+	for _, file := range files {
+		if !file.Type().IsRegular() || strings.HasSuffix(file.Name(), backupSuffix) || strings.HasSuffix(file.Name(), indexSuffix) {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		token, err := decode(raw)
+		if err != nil || token == nil || token.AccessToken == "" {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Key:   file.Name(),
+			Token: token,
+			Valid: token.Valid(),
+		})
+	}
+	return entries, nil
+}
+
+// decode parses the token file content.
+func (f *FileStore) decode(raw []byte) (*oauth2.Token, error) {
+	if f.hmacKey != nil {
+		if err := verifyToken(raw, f.hmacKey); err != nil {
+			return nil, err
+		}
+	}
+	if f.unmarshal != nil {
+		return f.unmarshal(raw)
+	}
+	return DecodeToken(raw)
+}
@@ -0,0 +1,70 @@
+package tokenstore
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultLockKey      = "otoken"
+	defaultLockTTL      = time.Minute
+	lockRecheckInterval = time.Second
+)
+
+// Locker is a distributed lock shared by CachedTokenSource instances
+// using the same store, e.g. a lock backed by Redis.
+type Locker interface {
+	// Lock acquires the lock of key which expires after ttl, it returns
+	// the function to release the lock, or error if the lock is not acquired.
+	Lock(ctx context.Context, key string, ttl time.Duration) (func(), error)
+}
+
+// WithDistributedLock makes CachedTokenSource acquire the lock before getting
+// a new token from `Src`, so only one of instances sharing the store gets new
+// token. Instances failed to acquire the lock wait and re-check the store.
+func WithDistributedLock(locker Locker) Option {
+	return &option{applyFunc: func(c *CachedTokenSource) {
+		c.locker = locker
+	}}
+}
+
+// WithLockKey sets the key and ttl of the distributed lock,
+// it defaults to `otoken` and 1 minute.
+func WithLockKey(key string, ttl time.Duration) Option {
+	return &option{applyFunc: func(c *CachedTokenSource) {
+		c.lockKey = key
+		c.lockTTL = ttl
+	}}
+}
+
+// acquireLock acquires the distributed lock. When the lock is held by others,
+// it waits until a valid token is saved into the store by the lock holder,
+// or the lock ttl is reached. It returns the valid token from store if any,
+// and the function to release the lock if it's acquired.
+func (c *CachedTokenSource) acquireLock() (*oauth2.Token, func()) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), c.lockTTL)
+	defer cancelFn()
+	ticker := time.NewTicker(lockRecheckInterval)
+	defer ticker.Stop()
+	for {
+		unlock, err := c.locker.Lock(ctx, c.lockKey, c.lockTTL)
+		// re-check the store in case the token is saved by the previous lock holder
+		if token, storeErr := c.Store.Token(); storeErr == nil && c.valid(token) {
+			if err == nil {
+				unlock()
+			}
+			return token, nil
+		}
+		if err == nil {
+			return nil, unlock
+		}
+		select {
+		case <-ctx.Done():
+			// give up waiting, get the token by ourselves
+			return nil, nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package tokenstore
+
+import (
+	"container/list"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Keyer is a Store identified by a key, e.g. a store keyed by user.
+type Keyer interface {
+	Key() string
+}
+
+// Key returns the file path as the store key.
+func (f *FileStore) Key() string {
+	return f.Path
+}
+
+type lruEntry struct {
+	key   string
+	token *oauth2.Token
+	// generation is increased by every Save, a token read from the
+	// underlying store is only cached if no Save happened meanwhile.
+	generation uint64
+}
+
+// lruStore caches the tokens of the underlying store in memory.
+type lruStore struct {
+	capacity   int
+	underlying Store
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+var _ Store = &lruStore{}
+var _ Keyer = &lruStore{}
+
+// LRUStore creates a Store caches the tokens of underlying in memory, it
+// holds the tokens of the capacity most recently used keys, the key is the
+// store key if underlying implements Keyer, e.g. the path of a FileStore.
+// Cached token is invalidated when it expires or a new token is saved.
+// The underlying store is read and written without holding the cache lock.
+func LRUStore(capacity int, underlying Store) Store {
+	return &lruStore{
+		capacity:   capacity,
+		underlying: underlying,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// Key returns the key of the underlying store, or empty string
+// if it doesn't implement Keyer.
+func (l *lruStore) Key() string {
+	if keyer, ok := l.underlying.(Keyer); ok {
+		return keyer.Key()
+	}
+	return ""
+}
+
+// entry returns the entry of key, it creates the entry if absent and
+// moves it to the front, it must be called with lock held.
+func (l *lruStore) entry(key string) *lruEntry {
+	if elem, ok := l.entries[key]; ok {
+		l.order.MoveToFront(elem)
+		return elem.Value.(*lruEntry)
+	}
+	entry := &lruEntry{key: key}
+	if l.capacity <= 0 {
+		return entry
+	}
+	l.entries[key] = l.order.PushFront(entry)
+	for l.order.Len() > l.capacity {
+		back := l.order.Back()
+		l.order.Remove(back)
+		delete(l.entries, back.Value.(*lruEntry).key)
+	}
+	return entry
+}
+
+func (l *lruStore) Token() (*oauth2.Token, error) {
+	key := l.Key()
+	l.mu.Lock()
+	entry := l.entry(key)
+	if entry.token.Valid() {
+		token := entry.token
+		l.mu.Unlock()
+		return token, nil
+	}
+	entry.token = nil
+	generation := entry.generation
+	l.mu.Unlock()
+
+	token, err := l.underlying.Token()
+	if err != nil {
+		return token, err
+	}
+	if token.Valid() {
+		l.mu.Lock()
+		if entry.generation == generation {
+			entry.token = token
+		}
+		l.mu.Unlock()
+	}
+	return token, nil
+}
+
+func (l *lruStore) Save(token *oauth2.Token) error {
+	key := l.Key()
+	l.mu.Lock()
+	entry := l.entry(key)
+	entry.token = nil
+	entry.generation++
+	generation := entry.generation
+	l.mu.Unlock()
+
+	if err := l.underlying.Save(token); err != nil {
+		return err
+	}
+	if token.Valid() {
+		l.mu.Lock()
+		if entry.generation == generation {
+			entry.token = token
+		}
+		l.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package tokenstore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultPruneAfter = 7 * 24 * time.Hour
+
+type pruneConfig struct {
+	expiredFor time.Duration
+}
+
+// PruneOption configures PruneExpiredTokens,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type PruneOption interface {
+	apply(*pruneConfig)
+}
+
+type pruneOption struct {
+	applyFunc func(*pruneConfig)
+}
+
+func (o pruneOption) apply(c *pruneConfig) {
+	o.applyFunc(c)
+}
+
+// PruneExpiredFor sets how long a token must have been expired
+// before it's pruned, it defaults to 7 days.
+func PruneExpiredFor(d time.Duration) PruneOption {
+	return &pruneOption{applyFunc: func(c *pruneConfig) {
+		c.expiredFor = d
+	}}
+}
+
+// PruneExpiredTokens walks storeDir and removes token files which have been
+// expired for a while, along with their `.bak` backup and the `.name` index
+// files of WithNamer pointing to them, files can't be parsed as token or
+// without expiry are kept. It returns the number of files removed.
+func PruneExpiredTokens(storeDir string, opts ...PruneOption) (int, error) {
+	cfg := &pruneConfig{
+		expiredFor: defaultPruneAfter,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(cfg)
+		}
+	}
+	deadline := time.Now().Add(-cfg.expiredFor)
+	removed := 0
+	err := filepath.WalkDir(storeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(path, indexSuffix) {
+			return nil
+		}
+		raw, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// a backup already removed with its token
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		token, err := DecodeToken(raw)
+		if err != nil || token.Expiry.IsZero() {
+			return nil
+		}
+		if token.Expiry.Before(deadline) {
+			n, err := removeTokenFiles(path)
+			removed += n
+			return err
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// removeTokenFiles removes the token file at path, its backup and the index
+// files in the same directory naming it, so no index redirects FileStore.Token
+// to the removed file. It returns the number of files removed.
+func removeTokenFiles(path string) (int, error) {
+	files := []string{path, path + backupSuffix}
+	dir, name := filepath.Split(path)
+	indexes, err := filepath.Glob(filepath.Join(dir, "*"+indexSuffix))
+	if err != nil {
+		return 0, err
+	}
+	for _, index := range indexes {
+		raw, err := os.ReadFile(index)
+		if err == nil && strings.TrimSpace(string(raw)) == name {
+			files = append(files, index)
+		}
+	}
+	removed := 0
+	for _, file := range files {
+		err := os.Remove(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
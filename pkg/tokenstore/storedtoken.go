@@ -0,0 +1,76 @@
+package tokenstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenVersion is the version of the current token file format.
+const TokenVersion = 1
+
+// storedToken is the token file format, version 0 is the legacy
+// format which is the plain oauth2.Token JSON.
+type storedToken struct {
+	Version   int             `json:"version"`
+	Token     *oauth2.Token   `json:"token"`
+	IssuedAt  time.Time       `json:"issued_at,omitempty"`
+	ExtraData json.RawMessage `json:"extra_data,omitempty"`
+}
+
+// extraFields are the extra fields of token preserved in ExtraData.
+var extraFields = []string{"id_token"}
+
+// EncodeToken serializes token in the current token file format, which
+// preserves the id_token, it can be used by other Store implementations.
+func EncodeToken(token *oauth2.Token) ([]byte, error) {
+	stored := &storedToken{
+		Version:  TokenVersion,
+		Token:    token,
+		IssuedAt: time.Now(),
+	}
+	extra := map[string]interface{}{}
+	for _, field := range extraFields {
+		if v := token.Extra(field); v != nil {
+			extra[field] = v
+		}
+	}
+	if len(extra) > 0 {
+		raw, err := json.Marshal(extra)
+		if err != nil {
+			return nil, err
+		}
+		stored.ExtraData = raw
+	}
+	return json.Marshal(stored)
+}
+
+// DecodeToken deserializes token encoded by EncodeToken, or in the legacy
+// format which is the plain oauth2.Token JSON.
+func DecodeToken(raw []byte) (*oauth2.Token, error) {
+	stored := &storedToken{}
+	if err := json.Unmarshal(raw, stored); err != nil {
+		return nil, err
+	}
+	if stored.Version == 0 {
+		token := &oauth2.Token{}
+		err := json.Unmarshal(raw, token)
+		return token, err
+	}
+	if stored.Version > TokenVersion {
+		return nil, fmt.Errorf("unsupported token file version %d, the latest supported version is %d", stored.Version, TokenVersion)
+	}
+	if stored.Token == nil {
+		return &oauth2.Token{}, nil
+	}
+	if len(stored.ExtraData) > 0 {
+		extra := map[string]interface{}{}
+		if err := json.Unmarshal(stored.ExtraData, &extra); err != nil {
+			return nil, err
+		}
+		return stored.Token.WithExtra(extra), nil
+	}
+	return stored.Token, nil
+}
@@ -1,10 +1,14 @@
 package tokenstore
 
 import (
-	"encoding/json"
 	"errors"
+	"io/fs"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/tiewei/otoken/pkg/refresher"
 	"golang.org/x/oauth2"
@@ -38,30 +42,155 @@ func (m *MemStore) Save(token *oauth2.Token) error {
 	return nil
 }
 
-// FileStore implements `Store` interface saves token in file
+// indexSuffix is the suffix of the index file records the name of the token
+// file set by WithNamer.
+const indexSuffix = ".name"
+
+// FileStore implements `Store` interface saves token in file,
+// the previous token is backed up to `<path>.bak` before saving.
 type FileStore struct {
 	Path string
+
+	marshal    func(*oauth2.Token) ([]byte, error)
+	unmarshal  func([]byte) (*oauth2.Token, error)
+	namer      func(clientID string, token *oauth2.Token) string
+	createDirs bool
+	hmacKey    []byte
+	mu         sync.Mutex
+}
+
+// NewPlatformFileStore creates a FileStore saves token at `<dir>/<appName>/<key>`,
+// where dir is the platform specific user config directory returned by
+// os.UserConfigDir, e.g. `%AppData%` on Windows, `~/Library/Application Support`
+// on macOS and `$XDG_CONFIG_HOME` on Linux. The directory is created if it
+// doesn't exist.
+func NewPlatformFileStore(appName string, key string) (*FileStore, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(configDir, appName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{Path: filepath.Join(dir, key)}, nil
+}
+
+// WithSerializer sets functions to serialize token in file, which can be
+// used to preserve the extra fields of token, it returns the FileStore itself.
+func (f *FileStore) WithSerializer(marshal func(*oauth2.Token) ([]byte, error), unmarshal func([]byte) (*oauth2.Token, error)) *FileStore {
+	f.marshal = marshal
+	f.unmarshal = unmarshal
+	return f
+}
+
+// WithNamer sets the function to name the token file in Save, it's called with
+// the file name of Path as clientID, and the token is saved in the returned name
+// under the directory of Path. Path itself is never changed, the name is recorded
+// in the index file `<path>.name`, hence Token of any FileStore with the same Path
+// reads the named file. It returns the FileStore itself.
+func (f *FileStore) WithNamer(namer func(clientID string, token *oauth2.Token) string) *FileStore {
+	f.namer = namer
+	return f
+}
+
+// WithCreateDirs creates the parent directories of the token file
+// in Save if they don't exist, it returns the FileStore itself.
+func (f *FileStore) WithCreateDirs(create bool) *FileStore {
+	f.createDirs = create
+	return f
+}
+
+// tokenPath returns the path of the token file, which is the named file
+// recorded in the index file if namer is set and the index exists.
+func (f *FileStore) tokenPath() string {
+	if f.namer == nil {
+		return f.Path
+	}
+	raw, err := os.ReadFile(f.Path + indexSuffix)
+	if err != nil {
+		return f.Path
+	}
+	name := strings.TrimSpace(string(raw))
+	if name == "" || name != filepath.Base(name) {
+		return f.Path
+	}
+	return filepath.Join(filepath.Dir(f.Path), name)
 }
 
 func (f *FileStore) Token() (*oauth2.Token, error) {
 	if f.Path == "" {
 		return nil, errors.New("path must not be empty")
 	}
-	raw, err := os.ReadFile(f.Path)
+	f.mu.Lock()
+	path := f.tokenPath()
+	f.mu.Unlock()
+	raw, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	token := &oauth2.Token{}
-	err = json.Unmarshal(raw, token)
+	token, err := f.decode(raw)
+	if err != nil && !errors.Is(err, ErrTokenTampered) {
+		// the token file is corrupted, try the backup, but a tampered
+		// file must be reported rather than hidden by the backup
+		if backup, backupErr := os.ReadFile(path + backupSuffix); backupErr == nil {
+			if backupToken, backupErr := f.decode(backup); backupErr == nil {
+				return backupToken, nil
+			}
+		}
+	}
 	return token, err
 }
 
 func (f *FileStore) Save(token *oauth2.Token) error {
-	raw, err := json.Marshal(token)
+	marshal := EncodeToken
+	if f.marshal != nil {
+		marshal = f.marshal
+	}
+	raw, err := marshal(token)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(f.Path, raw, 0600)
+	if f.hmacKey != nil {
+		if raw, err = signToken(raw, f.hmacKey); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := f.Path
+	name := ""
+	if f.namer != nil {
+		name = filepath.Base(f.namer(filepath.Base(f.Path), token))
+		if name == "." || name == string(filepath.Separator) {
+			name = ""
+		}
+		if name != "" {
+			path = filepath.Join(filepath.Dir(f.Path), name)
+		}
+	}
+	if f.createDirs {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+	}
+	if err := backupFile(path); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return err
+	}
+	if f.namer == nil {
+		return nil
+	}
+	if name == "" {
+		// the token is saved at Path, hence the stale index must not redirect reads
+		if err := os.Remove(f.Path + indexSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(f.Path+indexSuffix, []byte(name), 0600)
 }
 
 // CachedTokenSource is a TokenSource returns token from Store as long as
@@ -75,6 +204,104 @@ type CachedTokenSource struct {
 	Store     Store
 	Refresher *refresher.TokenRefresher
 	mu        sync.Mutex
+
+	minRemainingLife time.Duration
+	locker           Locker
+	lockKey          string
+	lockTTL          time.Duration
+	notifyCh         chan<- *oauth2.Token
+	reauthNotifier   func(clientID, issuer string) error
+	clientID         string
+	issuer           string
+}
+
+// Option configures optional field for CachedTokenSource,
+// it's an interface with private function, hence can
+// only be created within the pkg.
+type Option interface {
+	apply(*CachedTokenSource)
+}
+
+type option struct {
+	applyFunc func(*CachedTokenSource)
+}
+
+func (o option) apply(c *CachedTokenSource) {
+	o.applyFunc(c)
+}
+
+// WithMinRemainingLife treats a cached token as invalid if its remaining
+// lifetime is less than d, so it gets refreshed before it's about to expire.
+func WithMinRemainingLife(d time.Duration) Option {
+	return &option{applyFunc: func(c *CachedTokenSource) {
+		c.minRemainingLife = d
+	}}
+}
+
+// WithRefreshNotify sends the new token on ch whenever a token is acquired
+// by either refresh or `Src`. It never blocks, the token is dropped if ch is full.
+func WithRefreshNotify(ch chan<- *oauth2.Token) Option {
+	return &option{applyFunc: func(c *CachedTokenSource) {
+		c.notifyCh = ch
+	}}
+}
+
+// WithReauthNotifier calls notifier before getting token from `Src`, which
+// usually requires interactive re-authentication, so a human can be notified
+// (e.g. by Slack or webhook) to complete it. The clientID and issuer set by
+// WithClientInfo are passed to notifier, error of notifier is only logged.
+func WithReauthNotifier(notifier func(clientID, issuer string) error) Option {
+	return &option{applyFunc: func(c *CachedTokenSource) {
+		c.reauthNotifier = notifier
+	}}
+}
+
+// WithClientInfo sets the client ID and issuer of the cached token.
+func WithClientInfo(clientID string, issuer string) Option {
+	return &option{applyFunc: func(c *CachedTokenSource) {
+		c.clientID = clientID
+		c.issuer = issuer
+	}}
+}
+
+// NewCachedTokenSource creates a new CachedTokenSource reads token from store,
+// and uses refresher or src to get a new token when the cached one is invalid.
+func NewCachedTokenSource(src oauth2.TokenSource, store Store, r *refresher.TokenRefresher, opts ...Option) *CachedTokenSource {
+	c := &CachedTokenSource{
+		Src:       src,
+		Store:     store,
+		Refresher: r,
+		lockKey:   defaultLockKey,
+		lockTTL:   defaultLockTTL,
+	}
+	for _, op := range opts {
+		if op != nil {
+			op.apply(c)
+		}
+	}
+	return c
+}
+
+// valid reports whether token is valid and lives longer than minRemainingLife.
+func (c *CachedTokenSource) valid(token *oauth2.Token) bool {
+	if !token.Valid() {
+		return false
+	}
+	if c.minRemainingLife > 0 && !token.Expiry.IsZero() {
+		return time.Until(token.Expiry) >= c.minRemainingLife
+	}
+	return true
+}
+
+// notify sends token to the notify channel without blocking.
+func (c *CachedTokenSource) notify(token *oauth2.Token) {
+	if c.notifyCh == nil {
+		return
+	}
+	select {
+	case c.notifyCh <- token:
+	default:
+	}
 }
 
 func (c *CachedTokenSource) Token() (*oauth2.Token, error) {
@@ -83,24 +310,40 @@ func (c *CachedTokenSource) Token() (*oauth2.Token, error) {
 	var token *oauth2.Token
 	var err error
 	defer func() {
-		if err != nil && token.Valid() {
+		if err == nil && token.Valid() {
 			//nolint:errcheck
 			c.Store.Save(token)
+			c.notify(token)
 		}
 	}()
-	token, err = c.Store.Token()
-	if err == nil {
-		if token.Valid() {
-			return token, nil
+	cached, storeErr := c.Store.Token()
+	if storeErr == nil && cached != nil {
+		if c.valid(cached) {
+			return cached, nil
 		}
-		if token.RefreshToken != "" && c.Refresher != nil {
-			token, err = c.Refresher.Refresh(token.RefreshToken)
+		if cached.RefreshToken != "" && c.Refresher != nil {
+			token, err = c.Refresher.Refresh(cached.RefreshToken)
 			if err == nil {
 				return token, nil
 			}
 		}
 	}
 	if c.Src != nil {
+		if c.locker != nil {
+			var unlock func()
+			cached, unlock = c.acquireLock()
+			if unlock != nil {
+				defer unlock()
+			}
+			if cached != nil {
+				return cached, nil
+			}
+		}
+		if c.reauthNotifier != nil {
+			if err := c.reauthNotifier(c.clientID, c.issuer); err != nil {
+				log.Printf("failed to notify re-authentication: %s", err)
+			}
+		}
 		token, err = c.Src.Token()
 		return token, err
 	}
@@ -0,0 +1,111 @@
+package tokenstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCachedTokenSourceSavesNewToken(t *testing.T) {
+	// MemStore returns nil token and nil error when it's empty
+	store := &MemStore{}
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "new", Expiry: time.Now().Add(time.Hour)})
+	token, err := NewCachedTokenSource(src, store, nil).Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "new" {
+		t.Errorf("Token() = %+v, want access token new", token)
+	}
+	saved, _ := store.Token()
+	if saved == nil || saved.AccessToken != "new" {
+		t.Errorf("saved token = %+v, want the token from Src", saved)
+	}
+}
+
+func TestCachedTokenSourceUsesCachedToken(t *testing.T) {
+	store := &MemStore{}
+	//nolint:errcheck
+	store.Save(&oauth2.Token{AccessToken: "cached", Expiry: time.Now().Add(time.Hour)})
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "new", Expiry: time.Now().Add(time.Hour)})
+	token, err := NewCachedTokenSource(src, store, nil).Token()
+	if err != nil {
+		t.Fatalf("Token() returned error: %s", err)
+	}
+	if token.AccessToken != "cached" {
+		t.Errorf("Token() = %+v, want access token cached", token)
+	}
+}
+
+func TestDecodeTokenUnsupportedVersion(t *testing.T) {
+	if _, err := DecodeToken([]byte(`{"version":2,"token":{"access_token":"at"}}`)); err == nil {
+		t.Fatal("DecodeToken() returned no error for unsupported version")
+	}
+	token, err := DecodeToken([]byte(`{"access_token":"legacy"}`))
+	if err != nil || token.AccessToken != "legacy" {
+		t.Fatalf("DecodeToken() = %+v, %v, want legacy token", token, err)
+	}
+}
+
+// countingStore counts the reads of the embedded MemStore.
+type countingStore struct {
+	MemStore
+	reads int
+}
+
+func (c *countingStore) Token() (*oauth2.Token, error) {
+	c.reads++
+	return c.MemStore.Token()
+}
+
+func TestLRUStore(t *testing.T) {
+	underlying := &countingStore{}
+	store := LRUStore(1, underlying)
+	//nolint:errcheck
+	underlying.Save(&oauth2.Token{AccessToken: "first", Expiry: time.Now().Add(time.Hour)})
+	for i := 0; i < 2; i++ {
+		if token, err := store.Token(); err != nil || token.AccessToken != "first" {
+			t.Fatalf("Token() = %+v, %v, want access token first", token, err)
+		}
+	}
+	if underlying.reads != 1 {
+		t.Errorf("underlying store read %d times, want 1", underlying.reads)
+	}
+	if err := store.Save(&oauth2.Token{AccessToken: "second", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Save() returned error: %s", err)
+	}
+	if token, err := store.Token(); err != nil || token.AccessToken != "second" {
+		t.Errorf("Token() = %+v, %v, want the saved token", token, err)
+	}
+	if underlying.reads != 1 {
+		t.Errorf("underlying store read %d times after Save, want 1", underlying.reads)
+	}
+}
+
+func TestPruneExpiredTokensRemovesBackupAndIndex(t *testing.T) {
+	dir := t.TempDir()
+	store := (&FileStore{Path: filepath.Join(dir, "client")}).WithNamer(func(clientID string, _ *oauth2.Token) string {
+		return clientID + "-user"
+	})
+	expired := &oauth2.Token{AccessToken: "expired", Expiry: time.Now().Add(-time.Hour)}
+	for i := 0; i < 2; i++ {
+		// the second save backs up the first one
+		if err := store.Save(expired); err != nil {
+			t.Fatalf("Save() returned error: %s", err)
+		}
+	}
+	removed, err := PruneExpiredTokens(dir, PruneExpiredFor(0))
+	if err != nil {
+		t.Fatalf("PruneExpiredTokens() returned error: %s", err)
+	}
+	if removed != 3 {
+		t.Errorf("PruneExpiredTokens() removed %d files, want 3", removed)
+	}
+	left, _ := os.ReadDir(dir)
+	for _, file := range left {
+		t.Errorf("%s is left after pruning", file.Name())
+	}
+}
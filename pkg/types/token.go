@@ -0,0 +1,46 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// DPoPTokenType is the token type of DPoP-bound access tokens described in rfc9449.
+const DPoPTokenType = "DPoP"
+
+// IsDPoP reports whether token is a DPoP-bound access token, which requires
+// a DPoP proof on resource requests. The token type is case-insensitive.
+func IsDPoP(token *oauth2.Token) bool {
+	return token != nil && strings.EqualFold(token.TokenType, DPoPTokenType)
+}
+
+// tokenError is the error response of token endpoint.
+type tokenError struct {
+	*oauth2.RetrieveError
+}
+
+func (e *tokenError) Error() string {
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("failed to get token: %s, %s", e.ErrorCode, e.ErrorDescription)
+	}
+	return fmt.Sprintf("failed to get token: response code %d, %s", e.Response.StatusCode, string(e.Body))
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.RetrieveError
+}
+
+// TokenError converts the *oauth2.RetrieveError returned by the token endpoint
+// into an error reads the rfc6749 `error` and `error_description`, or the response
+// code and body if the response is not a JSON error. The *oauth2.RetrieveError
+// is still accessible by errors.As, other errors are returned as is.
+func TokenError(err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.Response != nil {
+		return &tokenError{RetrieveError: retrieveErr}
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+package types
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"os"
+	"time"
+)
+
+// UserAgentTransport is a http.RoundTripper sets `User-Agent` header
+// on every request before sending it by Base.
+type UserAgentTransport struct {
+	Base      http.RoundTripper
+	UserAgent string
+}
+
+func (t *UserAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.UserAgent)
+	return base.RoundTrip(req)
+}
+
+// WithUserAgent returns a copy of client whose transport sets `User-Agent`
+// header to ua, the client is returned as is if ua is empty.
+func WithUserAgent(client *http.Client, ua string) *http.Client {
+	if ua == "" {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &UserAgentTransport{Base: client.Transport, UserAgent: ua}
+	return &c
+}
+
+// WithTimeouts returns a copy of client with dial timeout set on its transport
+// and response timeout set as the client timeout, non-positive timeouts are
+// ignored. Dial timeout is ignored if the transport is not a *http.Transport.
+func WithTimeouts(client *http.Client, dial time.Duration, response time.Duration) *http.Client {
+	if dial <= 0 && response <= 0 {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	if response > 0 {
+		c.Timeout = response
+	}
+	if dial > 0 {
+		base := c.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		if t, ok := base.(*http.Transport); ok {
+			t = t.Clone()
+			dialer := &net.Dialer{Timeout: dial}
+			t.DialContext = dialer.DialContext
+			c.Transport = t
+		}
+	}
+	return &c
+}
+
+// DebugTransport is a http.RoundTripper dumps every request and response
+// to Writer, note the dump contains credentials like client secret and tokens.
+type DebugTransport struct {
+	Base   http.RoundTripper
+	Writer io.Writer
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+		fmt.Fprintf(t.Writer, "%s\n\n", dump)
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(t.Writer, "request error: %s\n\n", err)
+		return resp, err
+	}
+	if dump, err := httputil.DumpResponse(resp, true); err == nil {
+		fmt.Fprintf(t.Writer, "%s\n\n", dump)
+	}
+	return resp, nil
+}
+
+// WithDebugLogger returns a copy of client whose transport dumps requests and
+// responses to w, the client is returned as is if w is nil.
+func WithDebugLogger(client *http.Client, w io.Writer) *http.Client {
+	if w == nil {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &DebugTransport{Base: client.Transport, Writer: w}
+	return &c
+}
+
+// WithClientCertificate returns a copy of client presents cert in TLS
+// handshakes, e.g. for mutual TLS client authentication described in rfc8705.
+// If the client transport is not a *http.Transport, the default one is used.
+func WithClientCertificate(client *http.Client, cert tls.Certificate) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	t, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	c.Transport = t
+	return &c
+}
+
+// SlogTransport is a http.RoundTripper logs every request and response
+// at debug level by Logger.
+type SlogTransport struct {
+	Base   http.RoundTripper
+	Logger *slog.Logger
+}
+
+func (t *SlogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := req.Context()
+	if !t.Logger.Enabled(ctx, slog.LevelDebug) {
+		return base.RoundTrip(req)
+	}
+	start := time.Now()
+	t.Logger.DebugContext(ctx, "http request", "method", req.Method, "url", req.URL.String())
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		t.Logger.DebugContext(ctx, "http request failed", "method", req.Method, "url", req.URL.String(), "error", err)
+		return resp, err
+	}
+	t.Logger.DebugContext(ctx, "http response", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "duration", time.Since(start))
+	return resp, nil
+}
+
+// WithSlogLevelVar returns a copy of client logs requests and responses to
+// stderr at debug level, whether to log is decided by levelVar at runtime.
+// The client is returned as is if levelVar is nil.
+func WithSlogLevelVar(client *http.Client, levelVar *slog.LevelVar) *http.Client {
+	if levelVar == nil {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &SlogTransport{
+		Base:   client.Transport,
+		Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: levelVar})),
+	}
+	return &c
+}
+
+// TraceTransport is a http.RoundTripper attaches Trace to the context
+// of every request, e.g. to log DNS lookups, connections and TLS handshakes.
+type TraceTransport struct {
+	Base  http.RoundTripper
+	Trace *httptrace.ClientTrace
+}
+
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), t.Trace))
+	return base.RoundTrip(req)
+}
+
+// WithHTTPTrace returns a copy of client whose transport attaches trace to
+// every request, the client is returned as is if trace is nil.
+func WithHTTPTrace(client *http.Client, trace *httptrace.ClientTrace) *http.Client {
+	if trace == nil {
+		return client
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &TraceTransport{Base: client.Transport, Trace: trace}
+	return &c
+}
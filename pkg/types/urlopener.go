@@ -1,7 +1,13 @@
 package types
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
 
 	"github.com/pkg/browser"
 )
@@ -21,3 +27,50 @@ func PromptOpener(propter Prompter) URLOpener {
 		propter(fmt.Sprintf("Please open URL: %s", url), false)
 	}
 }
+
+// pipePollInterval is how often PipeOpener checks if the pipe has a reader.
+const pipePollInterval = 100 * time.Millisecond
+
+// PipeOpener opens URL by writing it to the named pipe at pipePath,
+// it blocks until the reader on the other end opens the pipe or
+// the timeout reaches, no timeout if timeout is not positive.
+func PipeOpener(pipePath string, timeout time.Duration) URLOpener {
+	return func(url string) {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if err := writePipe(ctx, pipePath, url); err != nil {
+			log.Printf("failed to write URL to pipe %s: %s", pipePath, err)
+		}
+	}
+}
+
+// writePipe writes url to the named pipe once a reader opens it. Opening a
+// FIFO for writing blocks until a reader opens it, hence it's opened without
+// blocking and retried until ctx is done, so nothing is left waiting.
+func writePipe(ctx context.Context, pipePath string, url string) error {
+	ticker := time.NewTicker(pipePollInterval)
+	defer ticker.Stop()
+	for {
+		f, err := os.OpenFile(pipePath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			_, err = fmt.Fprintln(f, url)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			return err
+		}
+		// ENXIO means the FIFO has no reader yet
+		if !errors.Is(err, syscall.ENXIO) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no reader opened the pipe: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}